@@ -0,0 +1,187 @@
+package alert
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/0x0BSoD/rtop/internal/output"
+	"github.com/0x0BSoD/rtop/pkg/logger"
+)
+
+// maxHistory is how many events are kept for display
+const maxHistory = 200
+
+// Event is an alert firing or resolving on a host
+type Event struct {
+	Rule     *Rule
+	Host     string
+	Resolved bool
+	Value    float64
+	At       time.Time
+}
+
+func (ev Event) State() string {
+	if ev.Resolved {
+		return "resolved"
+	}
+	return "firing"
+}
+
+// Alert is a rule currently firing on a host
+type Alert struct {
+	Rule  *Rule
+	Host  string
+	Since time.Time
+	Value float64
+}
+
+// ruleState tracks a rule on one host between rounds
+type ruleState struct {
+	pendingSince time.Time // when the condition started to hold
+	firing       bool
+	firedAt      time.Time
+	value        float64
+	last         float64 // for increased
+	hasLast      bool
+	warned       bool // that the metric is missing on the host
+}
+
+type stateKey struct {
+	host string
+	rule *Rule
+}
+
+// Engine evaluates the rules of a Config against the snapshots of every host.
+// It is safe for concurrent use.
+type Engine struct {
+	config  *Config
+	mu      sync.Mutex
+	states  map[stateKey]*ruleState
+	history []Event
+	running sync.WaitGroup // hooks
+}
+
+func NewEngine(config *Config) *Engine {
+	return &Engine{config: config, states: make(map[stateKey]*ruleState)}
+}
+
+// Observe evaluates every rule against a new snapshot of a host and runs the
+// hooks for the alerts that fired or resolved
+func (e *Engine) Observe(snap output.Snapshot) []Event {
+	e.mu.Lock()
+	var events []Event
+	for _, rule := range e.config.Rules {
+		key := stateKey{snap.Host, rule}
+		state, ok := e.states[key]
+		if !ok {
+			state = &ruleState{}
+			e.states[key] = state
+		}
+		if rule.metric.missing(snap) {
+			if !state.warned {
+				logger.Warn("Alert rule %s never holds on %s: no %s %q there", rule.Text, snap.Host, rule.metric.kind, rule.metric.key)
+				state.warned = true
+			}
+		} else if _, ok := rule.metric.Value(snap); ok {
+			state.warned = false
+		}
+		if ev, changed := state.observe(rule, snap); changed {
+			ev.Host = snap.Host
+			events = append(events, ev)
+		}
+	}
+	e.history = append(e.history, events...)
+	if n := len(e.history); n > maxHistory {
+		e.history = e.history[n-maxHistory:]
+	}
+	e.mu.Unlock()
+
+	for _, ev := range events {
+		logger.Warn("Alert %s on %s: %s (value %s)", ev.State(), ev.Host, ev.Rule.Text, strconv.FormatFloat(ev.Value, 'f', -1, 64))
+		for _, hook := range e.config.Hooks {
+			e.running.Add(1)
+			go func() {
+				defer e.running.Done()
+				ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+				defer cancel()
+				if err := hook.Run(ctx, ev); err != nil {
+					logger.Error("Alert hook failed: %v", err)
+				}
+			}()
+		}
+	}
+	return events
+}
+
+// observe moves the state of rule along, reporting when it fired or resolved
+func (s *ruleState) observe(rule *Rule, snap output.Snapshot) (Event, bool) {
+	v, ok := rule.metric.Value(snap)
+	holds := false
+	if ok {
+		if rule.op == "increased" {
+			holds = s.hasLast && v > s.last
+			s.last, s.hasLast = v, true
+		} else {
+			holds = ops[rule.op](v, rule.value)
+		}
+	}
+	s.value = v
+
+	at := snap.Timestamp
+	switch {
+	case holds && !s.firing:
+		if s.pendingSince.IsZero() {
+			s.pendingSince = at
+		}
+		if at.Sub(s.pendingSince) >= rule.For {
+			s.firing = true
+			s.firedAt = at
+			return Event{Rule: rule, Value: v, At: at}, true
+		}
+	case !holds:
+		s.pendingSince = time.Time{}
+		if s.firing {
+			s.firing = false
+			return Event{Rule: rule, Resolved: true, Value: v, At: at}, true
+		}
+	}
+	return Event{}, false
+}
+
+// Active lists the alerts firing on host, oldest first
+func (e *Engine) Active(host string) []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var alerts []Alert
+	for key, state := range e.states {
+		if key.host == host && state.firing {
+			alerts = append(alerts, Alert{Rule: key.rule, Host: host, Since: state.firedAt, Value: state.value})
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if !alerts[i].Since.Equal(alerts[j].Since) {
+			return alerts[i].Since.Before(alerts[j].Since)
+		}
+		return alerts[i].Rule.Text < alerts[j].Rule.Text
+	})
+	return alerts
+}
+
+// History lists past events, most recent first
+func (e *Engine) History() []Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	events := make([]Event, len(e.history))
+	for i, ev := range e.history {
+		events[len(events)-1-i] = ev
+	}
+	return events
+}
+
+// Wait waits for the hooks still running
+func (e *Engine) Wait() {
+	e.running.Wait()
+}
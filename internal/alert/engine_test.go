@@ -0,0 +1,133 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0x0BSoD/rtop/internal/output"
+)
+
+func engine(t *testing.T, rules ...string) *Engine {
+	t.Helper()
+	config := &Config{}
+	for _, text := range rules {
+		rule, err := ParseRule(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.Rules = append(config.Rules, rule)
+	}
+	return NewEngine(config)
+}
+
+func states(events []Event) []string {
+	var got []string
+	for _, ev := range events {
+		got = append(got, ev.State())
+	}
+	return got
+}
+
+func TestEngineFiresAndResolves(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	tests := []struct {
+		name  string
+		rule  string
+		snaps []output.Snapshot
+		want  []string // events per round, "" for none
+	}{
+		{
+			name: "at once",
+			rule: "cpu.iowait > 20",
+			snaps: []output.Snapshot{
+				{CPU: output.CPUSnapshot{Iowait: 10}},
+				{CPU: output.CPUSnapshot{Iowait: 30}},
+				{CPU: output.CPUSnapshot{Iowait: 40}},
+				{CPU: output.CPUSnapshot{Iowait: 5}},
+			},
+			want: []string{"", "firing", "", "resolved"},
+		},
+		{
+			name: "after holding for a while",
+			rule: "cpu.iowait > 20 for 20s",
+			snaps: []output.Snapshot{
+				{CPU: output.CPUSnapshot{Iowait: 30}},
+				{CPU: output.CPUSnapshot{Iowait: 30}},
+				{CPU: output.CPUSnapshot{Iowait: 5}},
+				{CPU: output.CPUSnapshot{Iowait: 30}},
+				{CPU: output.CPUSnapshot{Iowait: 30}},
+				{CPU: output.CPUSnapshot{Iowait: 30}},
+			},
+			want: []string{"", "", "", "", "", "firing"},
+		},
+		{
+			name: "increased",
+			rule: `cgroup["system.slice/nginx.service"].oom_kill increased`,
+			snaps: []output.Snapshot{
+				{Cgroups: []output.CgroupSnapshot{{Path: "/sys/fs/cgroup/system.slice/nginx.service", OomKills: 3}}},
+				{Cgroups: []output.CgroupSnapshot{{Path: "/sys/fs/cgroup/system.slice/nginx.service", OomKills: 4}}},
+				{Cgroups: []output.CgroupSnapshot{{Path: "/sys/fs/cgroup/system.slice/nginx.service", OomKills: 4}}},
+			},
+			want: []string{"", "firing", "resolved"},
+		},
+	}
+	for _, tt := range tests {
+		e := engine(t, tt.rule)
+		for i, snap := range tt.snaps {
+			snap.Host = "web1"
+			snap.Timestamp = at.Add(time.Duration(i) * 10 * time.Second)
+			got := ""
+			if events := e.Observe(snap); len(events) > 0 {
+				got = states(events)[0]
+			}
+			if got != tt.want[i] {
+				t.Errorf("%s: round %d: %q, want %q", tt.name, i, got, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestEngineActivePerHost(t *testing.T) {
+	e := engine(t, "load.1 > 4", "mem.used_pct > 90")
+	at := time.Unix(1700000000, 0)
+	e.Observe(output.Snapshot{Host: "web1", Timestamp: at, Load: [3]float64{8}})
+	e.Observe(output.Snapshot{Host: "db1", Timestamp: at, Memory: output.MemorySnapshot{Total: 100, Used: 95}})
+
+	for host, want := range map[string]string{"web1": "load.1 > 4", "db1": "mem.used_pct > 90"} {
+		active := e.Active(host)
+		if len(active) != 1 || active[0].Rule.Text != want {
+			t.Errorf("active on %s: %+v, want %s", host, active, want)
+		}
+	}
+	if n := len(e.History()); n != 2 {
+		t.Errorf("%d events in the history, want 2", n)
+	}
+}
+
+func TestEngineWarnsAboutMissingKeys(t *testing.T) {
+	e := engine(t, `fs["/data"].used_pct > 90`, "cpu.iowait > 20")
+	fs := []output.FilesystemSnapshot{{MountPoint: "/", Used: 1, Total: 2}}
+	failed := map[string]output.CollectorFail{"filesystems": {Reason: "df not found"}}
+
+	rounds := []struct {
+		snap   output.Snapshot
+		warned bool
+	}{
+		{output.Snapshot{Filesystems: fs}, true},
+		{output.Snapshot{Filesystems: fs}, true},
+		{output.Snapshot{Errors: failed}, true}, // not found, but not known missing either
+		{output.Snapshot{Reconnecting: true}, true},
+		{output.Snapshot{Filesystems: append(fs, output.FilesystemSnapshot{MountPoint: "/data"})}, false},
+		{output.Snapshot{Filesystems: fs}, true},
+	}
+	for i, round := range rounds {
+		round.snap.Host = "web1"
+		e.Observe(round.snap)
+		for key, state := range e.states {
+			want := round.warned && key.rule.metric.kind == "fs"
+			if state.warned != want {
+				t.Errorf("round %d: %s warned = %v, want %v", i, key.rule.Text, state.warned, want)
+			}
+		}
+	}
+}
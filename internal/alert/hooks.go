@@ -0,0 +1,77 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// hookTimeout bounds a single run of a hook
+const hookTimeout = 10 * time.Second
+
+// Hook is told about every alert that fires or resolves
+type Hook interface {
+	Run(ctx context.Context, ev Event) error
+}
+
+// execHook runs a shell command with the event in its environment
+type execHook struct {
+	command string
+}
+
+func (h *execHook) Run(ctx context.Context, ev Event) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.command)
+	cmd.Env = append(os.Environ(),
+		"RTOP_ALERT_STATE="+ev.State(),
+		"RTOP_ALERT_RULE="+ev.Rule.Text,
+		"RTOP_ALERT_HOST="+ev.Host,
+		"RTOP_ALERT_VALUE="+strconv.FormatFloat(ev.Value, 'f', -1, 64),
+		"RTOP_ALERT_TIME="+ev.At.Format(time.RFC3339),
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w: %s", h.command, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// webhook posts the event as JSON
+type webhook struct {
+	url string
+}
+
+func (h *webhook) Run(ctx context.Context, ev Event) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	// Rules are full of > and <
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(map[string]any{
+		"state": ev.State(),
+		"rule":  ev.Rule.Text,
+		"host":  ev.Host,
+		"value": ev.Value,
+		"time":  ev.At,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", h.url, resp.Status)
+	}
+	return nil
+}
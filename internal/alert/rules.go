@@ -0,0 +1,300 @@
+// Package alert evaluates threshold rules against every round of stats and
+// runs hooks when alerts fire and resolve.
+package alert
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0x0BSoD/rtop/internal/output"
+)
+
+// Panels an alert belongs to, for highlighting
+const (
+	PanelHeader = "header"
+	PanelCPU    = "cpu"
+	PanelMemory = "memory"
+	PanelFS     = "filesystems"
+	PanelNet    = "interfaces"
+	PanelCgroup = "cgroups"
+)
+
+// Metric is what a rule looks at, like cpu.iowait or fs["/"].used_pct
+type Metric struct {
+	kind  string
+	key   string // mount point, interface or cgroup, for the kinds that have one
+	field string
+}
+
+// fields lists what each kind of metric offers, whether it is keyed and the
+// collector that finds its keys
+var fields = map[string]struct {
+	keyed     bool
+	panel     string
+	fields    []string
+	collector string
+}{
+	"cpu":    {false, PanelCPU, []string{"user", "nice", "system", "idle", "iowait", "irq", "softirq", "steal", "guest", "used"}, ""},
+	"load":   {false, PanelHeader, []string{"1", "5", "15"}, ""},
+	"procs":  {false, PanelHeader, []string{"running", "total"}, ""},
+	"mem":    {false, PanelMemory, []string{"used_pct", "used", "free", "buffers", "cached", "total"}, ""},
+	"swap":   {false, PanelMemory, []string{"used_pct", "used", "free"}, ""},
+	"fs":     {true, PanelFS, []string{"used_pct", "used", "free", "total"}, "filesystems"},
+	"net":    {true, PanelNet, []string{"rx_rate", "tx_rate", "rx", "tx"}, "interfaces"},
+	"cgroup": {true, PanelCgroup, []string{"memory", "memory_pct", "cpu", "io_read", "io_write", "oom_kill"}, "cgroups"},
+}
+
+var metricPattern = regexp.MustCompile(`^([a-z]+)(?:\["([^"]*)"\])?\.([a-z0-9_]+)$`)
+
+// ParseMetric reads a metric as written in rules
+func ParseMetric(s string) (Metric, error) {
+	match := metricPattern.FindStringSubmatch(s)
+	if match == nil {
+		return Metric{}, fmt.Errorf("bad metric %q, want kind.field or kind[\"key\"].field", s)
+	}
+	m := Metric{kind: match[1], key: match[2], field: match[3]}
+	kind, ok := fields[m.kind]
+	if !ok {
+		return Metric{}, fmt.Errorf("unknown metric %q", s)
+	}
+	if kind.keyed != strings.Contains(s, "[") {
+		if kind.keyed {
+			return Metric{}, fmt.Errorf("%s metrics need a key, as in %s[\"...\"].%s", m.kind, m.kind, m.field)
+		}
+		return Metric{}, fmt.Errorf("%s metrics take no key", m.kind)
+	}
+	for _, f := range kind.fields {
+		if f == m.field {
+			return m, nil
+		}
+	}
+	return Metric{}, fmt.Errorf("unknown field %q of %s, want one of %s", m.field, m.kind, strings.Join(kind.fields, ", "))
+}
+
+func percent(part, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
+
+// Value looks the metric up in a snapshot, false when the host has no such
+// filesystem, interface or cgroup
+func (m Metric) Value(snap output.Snapshot) (float64, bool) {
+	switch m.kind {
+	case "cpu":
+		cpu := snap.CPU
+		return map[string]float64{
+			"user": cpu.User, "nice": cpu.Nice, "system": cpu.System, "idle": cpu.Idle,
+			"iowait": cpu.Iowait, "irq": cpu.Irq, "softirq": cpu.SoftIrq, "steal": cpu.Steal,
+			"guest": cpu.Guest, "used": 100 - cpu.Idle,
+		}[m.field], true
+	case "load":
+		return map[string]float64{"1": snap.Load[0], "5": snap.Load[1], "15": snap.Load[2]}[m.field], true
+	case "procs":
+		if m.field == "running" {
+			return float64(snap.Processes.Running), true
+		}
+		return float64(snap.Processes.Total), true
+	case "mem":
+		mem := snap.Memory
+		return map[string]float64{
+			"used_pct": percent(mem.Used, mem.Total), "used": float64(mem.Used), "free": float64(mem.Free),
+			"buffers": float64(mem.Buffers), "cached": float64(mem.Cached), "total": float64(mem.Total),
+		}[m.field], true
+	case "swap":
+		mem := snap.Memory
+		used := mem.SwapTotal - mem.SwapFree
+		return map[string]float64{
+			"used_pct": percent(used, mem.SwapTotal), "used": float64(used), "free": float64(mem.SwapFree),
+		}[m.field], true
+	case "fs":
+		for _, fs := range snap.Filesystems {
+			if fs.MountPoint == m.key {
+				return map[string]float64{
+					"used_pct": percent(fs.Used, fs.Total), "used": float64(fs.Used),
+					"free": float64(fs.Free), "total": float64(fs.Total),
+				}[m.field], true
+			}
+		}
+	case "net":
+		for _, intf := range snap.Interfaces {
+			if intf.Name == m.key {
+				return map[string]float64{
+					"rx_rate": intf.RxRate, "tx_rate": intf.TxRate, "rx": float64(intf.Rx), "tx": float64(intf.Tx),
+				}[m.field], true
+			}
+		}
+	case "cgroup":
+		if cg := findCgroup(snap.Cgroups, m.key); cg != nil {
+			memPct := 0.0
+			if cg.MemoryLimit > 0 {
+				memPct = float64(cg.Memory) / float64(cg.MemoryLimit) * 100
+			}
+			return map[string]float64{
+				"memory": float64(cg.Memory), "memory_pct": memPct, "cpu": cg.CPUUsage,
+				"io_read": float64(cg.IoRead), "io_write": float64(cg.IoWrite), "oom_kill": float64(cg.OomKills),
+			}[m.field], true
+		}
+	}
+	return 0, false
+}
+
+// missing tells whether the key of the metric is absent from a snapshot where
+// its collector worked, like a typo in a mount point
+func (m Metric) missing(snap output.Snapshot) bool {
+	collector := fields[m.kind].collector
+	if len(collector) == 0 || snap.Reconnecting {
+		return false
+	}
+	if _, failed := snap.Errors[collector]; failed {
+		return false
+	}
+	_, ok := m.Value(snap)
+	return !ok
+}
+
+// findCgroup finds a cgroup by its path below /sys/fs/cgroup
+func findCgroup(cgroups []output.CgroupSnapshot, path string) *output.CgroupSnapshot {
+	for i := range cgroups {
+		cg := &cgroups[i]
+		if strings.TrimPrefix(cg.Path, "/sys/fs/cgroup/") == path {
+			return cg
+		}
+		if found := findCgroup(cg.Children, path); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// Rule is one line of the rules file
+type Rule struct {
+	Text   string // as written, which also names the alert
+	metric Metric
+	op     string // a comparison, or increased
+	value  float64
+	For    time.Duration // how long the condition must hold before firing
+}
+
+// Panel is where the metric of the rule is shown
+func (r *Rule) Panel() string {
+	return fields[r.metric.kind].panel
+}
+
+var ops = map[string]func(a, b float64) bool{
+	">":  func(a, b float64) bool { return a > b },
+	">=": func(a, b float64) bool { return a >= b },
+	"<":  func(a, b float64) bool { return a < b },
+	"<=": func(a, b float64) bool { return a <= b },
+	"==": func(a, b float64) bool { return a == b },
+	"!=": func(a, b float64) bool { return a != b },
+}
+
+// parseQuantity reads a number, optionally followed by % or a binary size
+// suffix as in 512M
+func parseQuantity(s string) (float64, error) {
+	s = strings.TrimSuffix(s, "%")
+	mult := 1.0
+	if n := len(s); n > 0 {
+		if i := strings.IndexByte("KMGT", s[n-1]); i >= 0 {
+			mult = float64(uint64(1) << (10 * (i + 1)))
+			s = s[:n-1]
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad value %q", s)
+	}
+	return v * mult, nil
+}
+
+// ParseRule reads a rule like `cpu.iowait > 20 for 30s` or
+// `cgroup["system.slice/nginx.service"].oom_kill increased`
+func ParseRule(text string) (*Rule, error) {
+	words := strings.Fields(text)
+	if len(words) < 2 {
+		return nil, fmt.Errorf("bad rule %q, want metric op value [for duration]", text)
+	}
+	m, err := ParseMetric(words[0])
+	if err != nil {
+		return nil, err
+	}
+	r := &Rule{Text: strings.Join(words, " "), metric: m, op: words[1]}
+
+	rest := words[2:]
+	if r.op != "increased" {
+		if _, ok := ops[r.op]; !ok {
+			return nil, fmt.Errorf("bad operator %q in %q", r.op, text)
+		}
+		if len(rest) == 0 {
+			return nil, fmt.Errorf("missing value in %q", text)
+		}
+		if r.value, err = parseQuantity(rest[0]); err != nil {
+			return nil, fmt.Errorf("%w in %q", err, text)
+		}
+		rest = rest[1:]
+	}
+	if len(rest) == 2 && rest[0] == "for" {
+		if r.For, err = time.ParseDuration(rest[1]); err != nil {
+			return nil, fmt.Errorf("bad duration %q in %q", rest[1], text)
+		}
+		rest = rest[2:]
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("unexpected %q in %q", strings.Join(rest, " "), text)
+	}
+	return r, nil
+}
+
+// Config is a rules file: a rule per line, plus hooks run when an alert fires
+// or resolves, as
+//
+//	exec <shell command>
+//	webhook <url>
+//
+// Blank lines and lines starting with # are ignored.
+type Config struct {
+	Rules []*Rule
+	Hooks []Hook
+}
+
+func Load(path string) (*Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	config := &Config{}
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		word, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+		switch word {
+		case "exec":
+			config.Hooks = append(config.Hooks, &execHook{command: arg})
+		case "webhook":
+			config.Hooks = append(config.Hooks, &webhook{url: arg})
+		default:
+			rule, err := ParseRule(line)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+			}
+			config.Rules = append(config.Rules, rule)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return config, nil
+}
@@ -0,0 +1,90 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/0x0BSoD/rtop/internal/output"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		text string
+		want Rule
+		err  bool
+	}{
+		{text: "cpu.iowait > 20", want: Rule{metric: Metric{"cpu", "", "iowait"}, op: ">", value: 20}},
+		{text: "mem.used_pct >= 90% for 1m", want: Rule{metric: Metric{"mem", "", "used_pct"}, op: ">=", value: 90, For: time.Minute}},
+		{text: `fs["/"].free < 512M`, want: Rule{metric: Metric{"fs", "/", "free"}, op: "<", value: 512 << 20}},
+		{text: `net["eth0"].rx_rate > 1.5K for 30s`, want: Rule{metric: Metric{"net", "eth0", "rx_rate"}, op: ">", value: 1536, For: 30 * time.Second}},
+		{text: `cgroup["system.slice/nginx.service"].oom_kill increased`, want: Rule{metric: Metric{"cgroup", "system.slice/nginx.service", "oom_kill"}, op: "increased"}},
+		{text: "load.1   !=  2T", want: Rule{metric: Metric{"load", "", "1"}, op: "!=", value: 2 << 40}},
+		{text: "cpu.iowait", err: true},
+		{text: "cpu.iowait > ", err: true},
+		{text: "cpu.iowait => 20", err: true},
+		{text: "cpu.iowait > lots", err: true},
+		{text: "cpu.iowait > 20 for ever", err: true},
+		{text: "cpu.iowait > 20 for", err: true},
+		{text: "cpu.iowait increased 20", err: true},
+		{text: "cpu.wait > 20", err: true},
+		{text: `cpu["0"].idle < 5`, err: true},
+		{text: "fs.used_pct > 90", err: true},
+		{text: "disk.used > 1", err: true},
+		{text: "CPU.idle < 5", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRule(tt.text)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseRule(%q) = %+v, want an error", tt.text, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseRule(%q): %v", tt.text, err)
+			continue
+		}
+		tt.want.Text = got.Text
+		if *got != tt.want {
+			t.Errorf("ParseRule(%q) = %+v, want %+v", tt.text, *got, tt.want)
+		}
+	}
+}
+
+func TestMetricValue(t *testing.T) {
+	snap := output.Snapshot{
+		CPU:         output.CPUSnapshot{Idle: 70, Steal: 5},
+		Memory:      output.MemorySnapshot{Total: 4000, Used: 1000, SwapTotal: 200, SwapFree: 150},
+		Filesystems: []output.FilesystemSnapshot{{MountPoint: "/", Used: 30, Free: 70, Total: 100}},
+		Interfaces:  []output.InterfaceSnapshot{{Name: "eth0", RxRate: 1024}},
+		Cgroups: []output.CgroupSnapshot{{Path: "/sys/fs/cgroup/system.slice", Memory: 300, Children: []output.CgroupSnapshot{
+			{Path: "/sys/fs/cgroup/system.slice/nginx.service", Memory: 100, MemoryLimit: 400, OomKills: 2},
+		}}},
+	}
+	tests := []struct {
+		metric string
+		want   float64
+		ok     bool
+	}{
+		{"cpu.used", 30, true},
+		{"cpu.steal", 5, true},
+		{"mem.used_pct", 25, true},
+		{"swap.used", 50, true},
+		{`fs["/"].used_pct`, 30, true},
+		{`fs["/data"].used_pct`, 0, false},
+		{`net["eth0"].rx_rate`, 1024, true},
+		{`cgroup["system.slice"].memory`, 300, true},
+		{`cgroup["system.slice/nginx.service"].memory_pct`, 25, true},
+		{`cgroup["system.slice/nginx.service"].oom_kill`, 2, true},
+		{`cgroup["nginx.service"].oom_kill`, 0, false},
+	}
+	for _, tt := range tests {
+		m, err := ParseMetric(tt.metric)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, ok := m.Value(snap); got != tt.want || ok != tt.ok {
+			t.Errorf("%s = %v, %v, want %v, %v", tt.metric, got, ok, tt.want, tt.ok)
+		}
+	}
+}
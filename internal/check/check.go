@@ -0,0 +1,250 @@
+// Package check turns stats into the result of a monitoring plugin, as run by
+// Nagios or Icinga: a status line with performance data and an exit code.
+package check
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/0x0BSoD/rtop/internal/output"
+)
+
+// State is the outcome of a check, its value is the plugin exit code
+type State int
+
+const (
+	OK State = iota
+	Warning
+	Critical
+	Unknown
+)
+
+func (s State) String() string {
+	return [...]string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}[s]
+}
+
+// Threshold alerts when a value goes above Warn or Crit, NaN disables either
+type Threshold struct {
+	Warn float64
+	Crit float64
+}
+
+func NoThreshold() Threshold {
+	return Threshold{Warn: math.NaN(), Crit: math.NaN()}
+}
+
+func (t Threshold) set() bool {
+	return !math.IsNaN(t.Warn) || !math.IsNaN(t.Crit)
+}
+
+func (t Threshold) state(v float64) State {
+	if !math.IsNaN(t.Crit) && v > t.Crit {
+		return Critical
+	}
+	if !math.IsNaN(t.Warn) && v > t.Warn {
+		return Warning
+	}
+	return OK
+}
+
+// Rules are the thresholds of a check. Filesystem thresholds are by mount
+// point, the empty one applies to every filesystem without its own.
+type Rules struct {
+	CPU  Threshold // percent used
+	Mem  Threshold // percent used
+	Swap Threshold // percent used
+	Load Threshold // 1 minute load average
+	FS   map[string]Threshold
+}
+
+func NewRules() Rules {
+	return Rules{
+		CPU:  NoThreshold(),
+		Mem:  NoThreshold(),
+		Swap: NoThreshold(),
+		Load: NoThreshold(),
+		FS:   make(map[string]Threshold),
+	}
+}
+
+// ParseFS splits a filesystem threshold given as [mount:]percent
+func ParseFS(arg string) (mount string, value float64, err error) {
+	value, err = ParsePercent(arg)
+	if err == nil {
+		return "", value, nil
+	}
+	i := strings.LastIndexByte(arg, ':')
+	if i <= 0 {
+		return "", 0, fmt.Errorf("bad filesystem threshold %q, want [mount:]percent", arg)
+	}
+	value, err = ParsePercent(arg[i+1:])
+	if err != nil {
+		return "", 0, fmt.Errorf("bad filesystem threshold %q: %w", arg, err)
+	}
+	return arg[:i], value, nil
+}
+
+func ParsePercent(arg string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(arg, "%"), 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("bad threshold %q", arg)
+	}
+	return v, nil
+}
+
+// Result is what the plugin prints and exits with
+type Result struct {
+	State    State
+	Messages []string // problems first
+	Perfdata []string
+}
+
+func (r Result) String() string {
+	line := "RTOP " + r.State.String()
+	if len(r.Messages) > 0 {
+		line += " - " + strings.Join(r.Messages, ", ")
+	}
+	if len(r.Perfdata) > 0 {
+		line += " | " + strings.Join(r.Perfdata, " ")
+	}
+	return line
+}
+
+// Failed is the result of a check that could not collect anything
+func Failed(err error) Result {
+	return Result{State: Unknown, Messages: []string{err.Error()}}
+}
+
+type checker struct {
+	Result
+	fine []string // messages of the values that are OK, after the problems
+}
+
+func (c *checker) raise(s State) {
+	if s > c.State {
+		c.State = s
+	}
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func formatLimit(v float64) string {
+	if math.IsNaN(v) {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// perfLabel quotes labels the way the plugin guidelines ask for
+func perfLabel(label string) string {
+	if strings.ContainsAny(label, " ='") {
+		return "'" + strings.ReplaceAll(label, "'", "''") + "'"
+	}
+	return label
+}
+
+// value checks one metric and adds its performance data, max is NaN when
+// there is none. Metrics without thresholds are only mentioned in the status
+// line when asked to.
+func (c *checker) value(label string, v float64, unit string, t Threshold, max float64, mention bool) {
+	c.Perfdata = append(c.Perfdata, fmt.Sprintf("%s=%s%s;%s;%s;0;%s",
+		perfLabel(label), formatValue(v), unit, formatLimit(t.Warn), formatLimit(t.Crit), formatLimit(max)))
+	msg := fmt.Sprintf("%s %s%s", label, formatValue(v), unit)
+	if !t.set() {
+		if mention {
+			c.fine = append(c.fine, msg)
+		}
+		return
+	}
+	switch s := t.state(v); s {
+	case Critical:
+		c.Messages = append(c.Messages, fmt.Sprintf("%s > %s (critical)", msg, formatLimit(t.Crit)))
+		c.raise(s)
+	case Warning:
+		c.Messages = append(c.Messages, fmt.Sprintf("%s > %s (warning)", msg, formatLimit(t.Warn)))
+		c.raise(s)
+	default:
+		c.fine = append(c.fine, msg)
+	}
+}
+
+// unknown records a metric that has a threshold but could not be checked
+func (c *checker) unknown(msg string) {
+	c.Messages = append(c.Messages, msg)
+	c.raise(Unknown)
+}
+
+func percent(part, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
+
+// Evaluate checks rounds of a host against rules, averaging CPU usage over
+// all of them and taking everything else from the last one
+func Evaluate(rules Rules, snaps []output.Snapshot) Result {
+	if len(snaps) == 0 {
+		return Result{State: Unknown, Messages: []string{"no data collected"}}
+	}
+	last := snaps[len(snaps)-1]
+	var c checker
+
+	// The values of a collector that failed are left out rather than
+	// reported as zeros, and only make the check UNKNOWN when it has a threshold
+	failed := func(collector string, checked bool) bool {
+		fail, ok := last.Errors[collector]
+		if ok && checked {
+			c.unknown(fmt.Sprintf("%s unavailable: %s", collector, fail.Reason))
+		}
+		return ok
+	}
+
+	if !failed("cpu", rules.CPU.set()) {
+		var used float64
+		var rounds int
+		for _, snap := range snaps {
+			if _, ok := snap.Errors["cpu"]; !ok {
+				used += 100 - snap.CPU.Idle
+				rounds++
+			}
+		}
+		c.value("cpu", used/float64(rounds), "%", rules.CPU, 100, true)
+	}
+
+	if !failed("memory", rules.Mem.set() || rules.Swap.set()) {
+		c.value("mem", percent(last.Memory.Used, last.Memory.Total), "%", rules.Mem, 100, true)
+		c.value("swap", percent(last.Memory.SwapTotal-last.Memory.SwapFree, last.Memory.SwapTotal), "%", rules.Swap, 100, false)
+	}
+
+	if !failed("load", rules.Load.set()) {
+		c.value("load1", last.Load[0], "", rules.Load, math.NaN(), false)
+	}
+
+	if !failed("filesystems", len(rules.FS) > 0) {
+		mounted := make(map[string]bool, len(last.Filesystems))
+		for _, fs := range last.Filesystems {
+			mounted[fs.MountPoint] = true
+			t, ok := rules.FS[fs.MountPoint]
+			if !ok {
+				t, ok = rules.FS[""]
+			}
+			if !ok {
+				t = NoThreshold()
+			}
+			c.value(fs.MountPoint, percent(fs.Used, fs.Total), "%", t, 100, false)
+		}
+		for mount := range rules.FS {
+			if len(mount) > 0 && !mounted[mount] {
+				c.unknown("nothing mounted at " + mount)
+			}
+		}
+	}
+
+	c.Messages = append(c.Messages, c.fine...)
+	return c.Result
+}
@@ -0,0 +1,211 @@
+package check
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/0x0BSoD/rtop/internal/output"
+	"github.com/0x0BSoD/rtop/internal/stats"
+)
+
+// snapshot converts stats of a host with 25% CPU used and, of 4000 kB of
+// memory, 1000 free, 2000 holding buffers and cache and 1000 used
+func snapshot(change func(st *stats.Stats)) output.Snapshot {
+	f := stats.NewSshFetcher(nil)
+	f.Name = "web1"
+	*f.Stats = stats.Stats{
+		Load1:      "0.50",
+		MemTotal:   4000 << 10,
+		MemFree:    1000 << 10,
+		MemBuffers: 500 << 10,
+		MemCached:  1500 << 10,
+		SwapTotal:  1000 << 10,
+		SwapFree:   900 << 10,
+		FSInfos: []stats.FSInfo{
+			{Device: "/dev/sda1", MountPoint: "/", Used: 80, Free: 20},
+			{Device: "/dev/sdb1", MountPoint: "/data", Used: 50, Free: 50},
+		},
+		CPU: stats.CPUInfo{Idle: 75},
+	}
+	if change != nil {
+		change(f.Stats)
+	}
+	return output.NewSnapshot(f, time.Now())
+}
+
+func TestEvaluate(t *testing.T) {
+	threshold := func(warn, crit float64) Threshold {
+		return Threshold{Warn: warn, Crit: crit}
+	}
+	nan := math.NaN()
+
+	tests := []struct {
+		name  string
+		rules func(r *Rules)
+		snaps []output.Snapshot
+		state State
+		want  string
+	}{
+		{
+			name:  "no thresholds",
+			snaps: []output.Snapshot{snapshot(nil)},
+			state: OK,
+			want:  "RTOP OK - cpu 25.00%, mem 25.00% | cpu=25.00%;;;0;100 mem=25.00%;;;0;100 swap=10.00%;;;0;100 load1=0.50;;;0; /=80.00%;;;0;100 /data=50.00%;;;0;100",
+		},
+		{
+			name:  "cpu averaged over rounds",
+			rules: func(r *Rules) { r.CPU = threshold(30, 90) },
+			snaps: []output.Snapshot{
+				snapshot(func(st *stats.Stats) { st.CPU.Idle = 50 }),
+				snapshot(nil),
+			},
+			state: Warning,
+			want:  "RTOP WARNING - cpu 37.50% > 30 (warning), mem 25.00% | cpu=37.50%;30;90;0;100 mem=25.00%;;;0;100 swap=10.00%;;;0;100 load1=0.50;;;0; /=80.00%;;;0;100 /data=50.00%;;;0;100",
+		},
+		{
+			name:  "memory used leaves out buffers and cache",
+			rules: func(r *Rules) { r.Mem = threshold(30, 50) },
+			snaps: []output.Snapshot{snapshot(nil)},
+			state: OK,
+			want:  "RTOP OK - cpu 25.00%, mem 25.00% | cpu=25.00%;;;0;100 mem=25.00%;30;50;0;100 swap=10.00%;;;0;100 load1=0.50;;;0; /=80.00%;;;0;100 /data=50.00%;;;0;100",
+		},
+		{
+			name: "critical beats warning",
+			rules: func(r *Rules) {
+				r.Load = threshold(0.25, nan)
+				r.FS[""] = threshold(70, 75)
+			},
+			snaps: []output.Snapshot{snapshot(nil)},
+			state: Critical,
+			want:  "RTOP CRITICAL - load1 0.50 > 0.25 (warning), / 80.00% > 75 (critical), cpu 25.00%, mem 25.00%, /data 50.00% | cpu=25.00%;;;0;100 mem=25.00%;;;0;100 swap=10.00%;;;0;100 load1=0.50;0.25;;0; /=80.00%;70;75;0;100 /data=50.00%;70;75;0;100",
+		},
+		{
+			name: "mount threshold overrides the default",
+			rules: func(r *Rules) {
+				r.FS[""] = threshold(40, nan)
+				r.FS["/"] = threshold(90, nan)
+			},
+			snaps: []output.Snapshot{snapshot(nil)},
+			state: Warning,
+			want:  "RTOP WARNING - /data 50.00% > 40 (warning), cpu 25.00%, mem 25.00%, / 80.00% | cpu=25.00%;;;0;100 mem=25.00%;;;0;100 swap=10.00%;;;0;100 load1=0.50;;;0; /=80.00%;90;;0;100 /data=50.00%;40;;0;100",
+		},
+		{
+			name:  "missing mount",
+			rules: func(r *Rules) { r.FS["/var"] = threshold(90, nan) },
+			snaps: []output.Snapshot{snapshot(nil)},
+			state: Unknown,
+			want:  "RTOP UNKNOWN - nothing mounted at /var, cpu 25.00%, mem 25.00% | cpu=25.00%;;;0;100 mem=25.00%;;;0;100 swap=10.00%;;;0;100 load1=0.50;;;0; /=80.00%;;;0;100 /data=50.00%;;;0;100",
+		},
+		{
+			name:  "collector failed",
+			rules: func(r *Rules) { r.Mem = threshold(90, nan) },
+			snaps: []output.Snapshot{snapshot(func(st *stats.Stats) {
+				st.Errors = map[string]*stats.CollectorError{"memory": {Collector: "memory", Err: errors.New("permission denied")}}
+			})},
+			state: Unknown,
+			want:  "RTOP UNKNOWN - memory unavailable: permission denied, cpu 25.00% | cpu=25.00%;;;0;100 load1=0.50;;;0; /=80.00%;;;0;100 /data=50.00%;;;0;100",
+		},
+		{
+			name: "collectors failed without thresholds",
+			snaps: []output.Snapshot{snapshot(func(st *stats.Stats) {
+				st.CPU.Idle = 0
+				st.Load1 = ""
+				st.Errors = map[string]*stats.CollectorError{
+					"cpu":  {Collector: "cpu", Err: errors.New("permission denied")},
+					"load": {Collector: "load", Err: errors.New("permission denied")},
+				}
+			})},
+			state: OK,
+			want:  "RTOP OK - mem 25.00% | mem=25.00%;;;0;100 swap=10.00%;;;0;100 /=80.00%;;;0;100 /data=50.00%;;;0;100",
+		},
+		{
+			name: "memory failed without thresholds",
+			snaps: []output.Snapshot{snapshot(func(st *stats.Stats) {
+				st.MemTotal, st.MemFree, st.MemBuffers, st.MemCached, st.SwapTotal, st.SwapFree = 0, 0, 0, 0, 0, 0
+				st.Errors = map[string]*stats.CollectorError{"memory": {Collector: "memory", Err: errors.New("permission denied")}}
+			})},
+			state: OK,
+			want:  "RTOP OK - cpu 25.00% | cpu=25.00%;;;0;100 load1=0.50;;;0; /=80.00%;;;0;100 /data=50.00%;;;0;100",
+		},
+		{
+			name:  "cpu averaged over the rounds it worked",
+			rules: func(r *Rules) { r.CPU = threshold(30, 90) },
+			snaps: []output.Snapshot{
+				snapshot(func(st *stats.Stats) {
+					st.CPU.Idle = 0
+					st.Errors = map[string]*stats.CollectorError{"cpu": {Collector: "cpu", Err: errors.New("timed out")}}
+				}),
+				snapshot(nil),
+			},
+			state: OK,
+			want:  "RTOP OK - cpu 25.00%, mem 25.00% | cpu=25.00%;30;90;0;100 mem=25.00%;;;0;100 swap=10.00%;;;0;100 load1=0.50;;;0; /=80.00%;;;0;100 /data=50.00%;;;0;100",
+		},
+		{
+			name:  "cpu failed with a threshold",
+			rules: func(r *Rules) { r.CPU = threshold(30, 90) },
+			snaps: []output.Snapshot{snapshot(func(st *stats.Stats) {
+				st.CPU.Idle = 0
+				st.Errors = map[string]*stats.CollectorError{"cpu": {Collector: "cpu", Err: errors.New("timed out")}}
+			})},
+			state: Unknown,
+			want:  "RTOP UNKNOWN - cpu unavailable: timed out, mem 25.00% | mem=25.00%;;;0;100 swap=10.00%;;;0;100 load1=0.50;;;0; /=80.00%;;;0;100 /data=50.00%;;;0;100",
+		},
+		{
+			name:  "no data",
+			state: Unknown,
+			want:  "RTOP UNKNOWN - no data collected",
+		},
+	}
+	for _, tt := range tests {
+		rules := NewRules()
+		if tt.rules != nil {
+			tt.rules(&rules)
+		}
+		result := Evaluate(rules, tt.snaps)
+		if result.State != tt.state {
+			t.Errorf("%s: state = %v, want %v", tt.name, result.State, tt.state)
+		}
+		if got := result.String(); got != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStateExitCodes(t *testing.T) {
+	for state, code := range map[State]int{OK: 0, Warning: 1, Critical: 2, Unknown: 3} {
+		if int(state) != code {
+			t.Errorf("%v exits with %d, want %d", state, int(state), code)
+		}
+	}
+}
+
+func TestParseFS(t *testing.T) {
+	tests := []struct {
+		arg   string
+		mount string
+		value float64
+		err   bool
+	}{
+		{arg: "90", value: 90},
+		{arg: "90%", value: 90},
+		{arg: "/:85", mount: "/", value: 85},
+		{arg: "/mnt/a:b:70%", mount: "/mnt/a:b", value: 70},
+		{arg: "/var", err: true},
+		{arg: ":90", err: true},
+		{arg: "/:-1", err: true},
+	}
+	for _, tt := range tests {
+		mount, value, err := ParseFS(tt.arg)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseFS(%q) = %q, %v, want an error", tt.arg, mount, value)
+			}
+			continue
+		}
+		if err != nil || mount != tt.mount || value != tt.value {
+			t.Errorf("ParseFS(%q) = %q, %v, %v, want %q, %v", tt.arg, mount, value, err, tt.mount, tt.value)
+		}
+	}
+}
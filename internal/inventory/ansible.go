@@ -0,0 +1,378 @@
+package inventory
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The connection variables rtop understands, the rest are ignored
+const (
+	varHost = "ansible_host"
+	varPort = "ansible_port"
+	varUser = "ansible_user"
+)
+
+// ansibleGroup is a group while parsing, before children are flattened
+type ansibleGroup struct {
+	hosts    []string
+	children []string
+	vars     map[string]string
+}
+
+type ansibleParser struct {
+	groups   map[string]*ansibleGroup
+	order    []string                     // hosts in the order they first appear
+	hostVars map[string]map[string]string // by host name
+}
+
+func newAnsibleParser() *ansibleParser {
+	return &ansibleParser{
+		groups:   make(map[string]*ansibleGroup),
+		hostVars: make(map[string]map[string]string),
+	}
+}
+
+func (p *ansibleParser) group(name string) *ansibleGroup {
+	g, ok := p.groups[name]
+	if !ok {
+		g = &ansibleGroup{vars: make(map[string]string)}
+		p.groups[name] = g
+	}
+	return g
+}
+
+func (p *ansibleParser) addHost(group, pattern string, vars map[string]string) error {
+	names, err := expandRange(pattern)
+	if err != nil {
+		return err
+	}
+	g := p.group(group)
+	for _, name := range names {
+		if _, ok := p.hostVars[name]; !ok {
+			p.hostVars[name] = make(map[string]string)
+			p.order = append(p.order, name)
+		}
+		for k, v := range vars {
+			p.hostVars[name][k] = v
+		}
+		g.hosts = append(g.hosts, name)
+	}
+	return nil
+}
+
+// members returns the hosts of a group and its children, recursively
+func (p *ansibleParser) members(name string, seen map[string]bool) []string {
+	if seen[name] {
+		return nil
+	}
+	seen[name] = true
+	g, ok := p.groups[name]
+	if !ok {
+		return nil
+	}
+	hosts := append([]string(nil), g.hosts...)
+	for _, child := range g.children {
+		hosts = append(hosts, p.members(child, seen)...)
+	}
+	return hosts
+}
+
+// depth is how many levels of parent groups a group has
+func (p *ansibleParser) depth(name string, seen map[string]bool) int {
+	if seen[name] {
+		return 0
+	}
+	seen[name] = true
+	d := 0
+	for parent, g := range p.groups {
+		for _, child := range g.children {
+			if child == name {
+				if pd := p.depth(parent, seen) + 1; pd > d {
+					d = pd
+				}
+			}
+		}
+	}
+	return d
+}
+
+// inventory flattens the groups. Like in Ansible, host variables win over
+// group variables, child groups over their parents, and between groups of
+// the same depth the last one by name wins; all comes last.
+func (p *ansibleParser) inventory() *Inventory {
+	names := make([]string, 0, len(p.groups))
+	depths := make(map[string]int, len(p.groups))
+	for name := range p.groups {
+		names = append(names, name)
+		depths[name] = p.depth(name, make(map[string]bool))
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if depths[names[i]] != depths[names[j]] {
+			return depths[names[i]] > depths[names[j]]
+		}
+		return names[i] > names[j]
+	})
+
+	groups := make(map[string][]string)
+	for _, name := range names {
+		// Every host is in all, which InGroup knows
+		if name == "all" {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, host := range p.members(name, make(map[string]bool)) {
+			if !seen[host] {
+				seen[host] = true
+				groups[host] = append(groups[host], name)
+			}
+		}
+	}
+
+	inv := New()
+	for _, name := range p.order {
+		h := Host{Name: name, Groups: groups[name]}
+		vars := make(map[string]string)
+		for k, v := range p.hostVars[name] {
+			vars[k] = v
+		}
+		scopes := h.Groups
+		if _, ok := p.groups["all"]; ok {
+			scopes = append(scopes, "all")
+		}
+		for _, g := range scopes {
+			for k, v := range p.groups[g].vars {
+				if _, ok := vars[k]; !ok {
+					vars[k] = v
+				}
+			}
+		}
+		h.Address = vars[varHost]
+		h.User = vars[varUser]
+		h.Port, _ = strconv.Atoi(vars[varPort])
+		if len(h.Groups) == 0 {
+			h.Groups = []string{"ungrouped"}
+		}
+		inv.Add(h)
+	}
+	return inv
+}
+
+// expandRange expands Ansible host ranges such as web[01:10].example.com or
+// db-[a:c], keeping the zero padding of numeric ranges
+func expandRange(pattern string) ([]string, error) {
+	start := strings.Index(pattern, "[")
+	if start == -1 {
+		return []string{pattern}, nil
+	}
+	end := strings.Index(pattern[start:], "]")
+	if end == -1 {
+		return nil, fmt.Errorf("unterminated range in %q", pattern)
+	}
+	end += start
+	prefix, spec, suffix := pattern[:start], pattern[start+1:end], pattern[end+1:]
+
+	bounds := strings.Split(spec, ":")
+	if len(bounds) < 2 || len(bounds) > 3 {
+		return nil, fmt.Errorf("bad range %q", spec)
+	}
+	step := 1
+	if len(bounds) == 3 {
+		var err error
+		if step, err = strconv.Atoi(bounds[2]); err != nil || step <= 0 {
+			return nil, fmt.Errorf("bad range step in %q", spec)
+		}
+	}
+
+	var items []string
+	from, errFrom := strconv.Atoi(bounds[0])
+	to, errTo := strconv.Atoi(bounds[1])
+	switch {
+	case errFrom == nil && errTo == nil && from <= to:
+		width := len(bounds[0])
+		for i := from; i <= to; i += step {
+			items = append(items, fmt.Sprintf("%0*d", width, i))
+		}
+	case errFrom != nil && errTo != nil && len(bounds[0]) == 1 && len(bounds[1]) == 1 && bounds[0] <= bounds[1]:
+		for c := int(bounds[0][0]); c <= int(bounds[1][0]); c += step {
+			items = append(items, string(rune(c)))
+		}
+	default:
+		return nil, fmt.Errorf("bad range %q", spec)
+	}
+
+	var names []string
+	for _, item := range items {
+		rest, err := expandRange(suffix)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rest {
+			names = append(names, prefix+item+r)
+		}
+	}
+	return names, nil
+}
+
+// LoadAnsible reads an Ansible inventory in INI or YAML format
+func LoadAnsible(file string) (*Inventory, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	p := newAnsibleParser()
+	switch ext := filepath.Ext(file); {
+	case ext == ".yml" || ext == ".yaml" || looksLikeYAML(data):
+		err = p.parseYAML(data)
+	default:
+		err = p.parseINI(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse inventory %s: %w", file, err)
+	}
+	return p.inventory(), nil
+}
+
+// looksLikeYAML tells YAML inventories without an extension from INI ones
+func looksLikeYAML(data []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		return line == "---" || strings.HasSuffix(line, ":")
+	}
+	return false
+}
+
+// parseINI reads the INI format: [group], [group:children] and [group:vars]
+// sections, with host lines carrying key=value variables
+func (p *ansibleParser) parseINI(data []byte) error {
+	section, kind := "ungrouped", ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			section, kind, _ = strings.Cut(line[1:len(line)-1], ":")
+			p.group(section)
+			continue
+		}
+
+		fields := strings.Fields(line)
+		switch kind {
+		case "children":
+			g := p.group(section)
+			g.children = append(g.children, fields[0])
+			p.group(fields[0])
+		case "vars":
+			k, v, ok := strings.Cut(line, "=")
+			if !ok {
+				return fmt.Errorf("line %d: expected key=value", n)
+			}
+			p.group(section).vars[strings.TrimSpace(k)] = unquote(strings.TrimSpace(v))
+		case "":
+			vars := make(map[string]string)
+			for _, f := range fields[1:] {
+				if k, v, ok := strings.Cut(f, "="); ok {
+					vars[k] = unquote(v)
+				}
+			}
+			if err := p.addHost(section, fields[0], vars); err != nil {
+				return fmt.Errorf("line %d: %w", n, err)
+			}
+		default:
+			return fmt.Errorf("line %d: unknown section type %q", n, kind)
+		}
+	}
+	return scanner.Err()
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// parseYAML reads the YAML format, a tree of groups with hosts, vars and
+// children. The document is walked as nodes to keep the order of hosts.
+func (p *ansibleParser) parseYAML(data []byte) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a mapping of groups", root.Line)
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if err := p.yamlGroup(root.Content[i].Value, root.Content[i+1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *ansibleParser) yamlGroup(name string, node *yaml.Node) error {
+	g := p.group(name)
+	if node.Kind != yaml.MappingNode {
+		// An empty group is written as "name:" and comes out null
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		if value.Kind != yaml.MappingNode {
+			continue
+		}
+		switch key {
+		case "hosts":
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				if err := p.addHost(name, value.Content[j].Value, yamlVars(value.Content[j+1])); err != nil {
+					return fmt.Errorf("line %d: %w", value.Content[j].Line, err)
+				}
+			}
+		case "vars":
+			for k, v := range yamlVars(value) {
+				g.vars[k] = v
+			}
+		case "children":
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				child := value.Content[j].Value
+				g.children = append(g.children, child)
+				if err := p.yamlGroup(child, value.Content[j+1]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// yamlVars returns the scalar variables of a mapping node
+func yamlVars(node *yaml.Node) map[string]string {
+	vars := make(map[string]string)
+	if node.Kind != yaml.MappingNode {
+		return vars
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if v := node.Content[i+1]; v.Kind == yaml.ScalarNode {
+			vars[node.Content[i].Value] = v.Value
+		}
+	}
+	return vars
+}
@@ -0,0 +1,189 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandRange(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+		err     bool
+	}{
+		{pattern: "web1.example.com", want: []string{"web1.example.com"}},
+		{pattern: "web[01:03].example.com", want: []string{"web01.example.com", "web02.example.com", "web03.example.com"}},
+		{pattern: "web[8:10]", want: []string{"web8", "web9", "web10"}},
+		{pattern: "db-[a:c]", want: []string{"db-a", "db-b", "db-c"}},
+		{pattern: "n[1:10:4]", want: []string{"n1", "n5", "n9"}},
+		{pattern: "n[y:z:200]", want: []string{"ny"}},
+		{pattern: "r[1:2]-[a:b]", want: []string{"r1-a", "r1-b", "r2-a", "r2-b"}},
+		{pattern: "web[01:03", err: true},
+		{pattern: "web[1]", err: true},
+		{pattern: "web[1:2:3:4]", err: true},
+		{pattern: "web[1:5:0]", err: true},
+		{pattern: "web[1:x]", err: true},
+		{pattern: "web[a:9]", err: true},
+		{pattern: "web[aa:c]", err: true},
+		{pattern: "web[03:01]", err: true},
+		{pattern: "web[c:a]", err: true},
+		{pattern: "r[1:2]-[b", err: true},
+	}
+	for _, tt := range tests {
+		got, err := expandRange(tt.pattern)
+		if tt.err {
+			if err == nil {
+				t.Errorf("expandRange(%q) = %v, want an error", tt.pattern, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandRange(%q) = %v, %v, want %v", tt.pattern, got, err, tt.want)
+		}
+	}
+}
+
+// The same inventory in both formats: host variables win over group ones,
+// child groups over their parents, and web over db, being later by name
+const iniInventory = `# ungrouped first
+bastion ansible_host=203.0.113.1
+
+[web]
+web[01:02].example.com
+shared
+
+[web:vars]
+ansible_user=webadmin
+
+[db]
+db1 ansible_host=10.0.0.5 ansible_port=2222 ansible_user="dba"
+shared
+
+[db:vars]
+ansible_user = dbadmin
+ansible_port=5022
+
+[prod:children]
+web
+db
+
+[prod:vars]
+ansible_user=admin
+ansible_port=22
+
+; all comes last
+[all:vars]
+ansible_port=2200
+ansible_user=root
+`
+
+const yamlInventory = `all:
+  hosts:
+    bastion:
+      ansible_host: 203.0.113.1
+  vars:
+    ansible_port: 2200
+    ansible_user: root
+  children:
+    prod:
+      vars:
+        ansible_user: admin
+        ansible_port: 22
+      children:
+        web:
+          hosts:
+            web[01:02].example.com:
+            shared:
+          vars:
+            ansible_user: webadmin
+        db:
+          hosts:
+            db1:
+              ansible_host: 10.0.0.5
+              ansible_port: 2222
+              ansible_user: dba
+            shared:
+          vars:
+            ansible_user: dbadmin
+            ansible_port: 5022
+`
+
+func TestLoadAnsible(t *testing.T) {
+	want := []Host{
+		{Name: "bastion", Address: "203.0.113.1", User: "root", Port: 2200, Groups: []string{"ungrouped"}},
+		{Name: "web01.example.com", User: "webadmin", Port: 22, Groups: []string{"web", "prod"}},
+		{Name: "web02.example.com", User: "webadmin", Port: 22, Groups: []string{"web", "prod"}},
+		{Name: "shared", User: "webadmin", Port: 5022, Groups: []string{"web", "db", "prod"}},
+		{Name: "db1", Address: "10.0.0.5", User: "dba", Port: 2222, Groups: []string{"db", "prod"}},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		file string
+		data string
+	}{
+		{"hosts.ini", iniInventory},
+		{"hosts", iniInventory},
+		{"hosts.yml", yamlInventory},
+		{"hosts-yaml", "---\n" + yamlInventory},
+	} {
+		path := filepath.Join(dir, tt.file)
+		if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		inv, err := LoadAnsible(path)
+		if err != nil {
+			t.Errorf("%s: %v", tt.file, err)
+			continue
+		}
+		if !reflect.DeepEqual(inv.Hosts, want) {
+			t.Errorf("%s: loaded\n%+v\nwant\n%+v", tt.file, inv.Hosts, want)
+		}
+	}
+}
+
+func TestLoadAnsibleEmptyGroups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.yml")
+	data := "all:\n  children:\n    empty:\n    web:\n      hosts:\n        web1:\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	inv, err := LoadAnsible(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Host{{Name: "web1", Groups: []string{"web"}}}
+	if !reflect.DeepEqual(inv.Hosts, want) {
+		t.Errorf("loaded %+v, want %+v", inv.Hosts, want)
+	}
+}
+
+func TestLoadAnsibleErrors(t *testing.T) {
+	tests := []struct {
+		file string
+		data string
+		err  string
+	}{
+		{"bad-range.ini", "[web]\nweb1\nweb[3:1]\n", "line 3: bad range"},
+		{"bad-vars.ini", "[web:vars]\nansible_user\n", "line 2: expected key=value"},
+		{"bad-section.ini", "[web:hosts]\nweb1\n", `unknown section type "hosts"`},
+		{"bad-range.yml", "web:\n  hosts:\n    web[1:\n", "line 3: unterminated range"},
+		{"list.yml", "- web1\n- web2\n", "expected a mapping of groups"},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.file)
+		if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadAnsible(path); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error %v, want %q", tt.file, err, tt.err)
+		}
+	}
+	if _, err := LoadAnsible(filepath.Join(dir, "missing")); err == nil {
+		t.Error("loading a missing inventory did not fail")
+	}
+}
@@ -0,0 +1,218 @@
+// Package inventory loads the hosts to monitor from Ansible inventories,
+// ssh_config and plain host lists.
+package inventory
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Host is a machine to monitor
+type Host struct {
+	Name    string // inventory name or alias, shown to the user
+	Address string // what to connect to, Name when empty
+	Port    int    // 0 leaves the port to ssh_config or the default
+	User    string // empty leaves the user to ssh_config or the default
+	Groups  []string
+}
+
+// Target renders the host as [user@]host[:port]
+func (h Host) Target() string {
+	t := h.Address
+	if len(t) == 0 {
+		t = h.Name
+	}
+	if len(h.User) > 0 {
+		t = h.User + "@" + t
+	}
+	if h.Port != 0 {
+		t += ":" + strconv.Itoa(h.Port)
+	}
+	return t
+}
+
+func (h Host) InGroup(group string) bool {
+	if group == "all" {
+		return true
+	}
+	for _, g := range h.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// Inventory is an ordered set of hosts, unique by name
+type Inventory struct {
+	Hosts []Host
+	index map[string]int
+}
+
+func New() *Inventory {
+	return &Inventory{index: make(map[string]int)}
+}
+
+// Add appends a host, or merges its groups into the one of the same name
+func (inv *Inventory) Add(h Host) {
+	if inv.index == nil {
+		inv.index = make(map[string]int)
+	}
+	i, ok := inv.index[h.Name]
+	if !ok {
+		inv.index[h.Name] = len(inv.Hosts)
+		inv.Hosts = append(inv.Hosts, h)
+		return
+	}
+	existing := &inv.Hosts[i]
+	for _, g := range h.Groups {
+		if !existing.InGroup(g) {
+			existing.Groups = append(existing.Groups, g)
+		}
+	}
+	if len(existing.Address) == 0 {
+		existing.Address = h.Address
+	}
+	if existing.Port == 0 {
+		existing.Port = h.Port
+	}
+	if len(existing.User) == 0 {
+		existing.User = h.User
+	}
+}
+
+// Merge adds every host of other
+func (inv *Inventory) Merge(other *Inventory) {
+	for _, h := range other.Hosts {
+		inv.Add(h)
+	}
+}
+
+// Groups lists the group names used by the hosts
+func (inv *Inventory) Groups() []string {
+	seen := make(map[string]bool)
+	var groups []string
+	for _, h := range inv.Hosts {
+		for _, g := range h.Groups {
+			if !seen[g] {
+				seen[g] = true
+				groups = append(groups, g)
+			}
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// Select returns the hosts of groups, given like Ansible patterns separated
+// by commas or colons: the hosts in any of the plain groups (all of them when
+// there are none), that are also in every group prefixed with & and in none
+// prefixed with !, such as web:&prod:!staging
+func (inv *Inventory) Select(groups string) ([]Host, error) {
+	var include, require, exclude []string
+	for _, term := range strings.FieldsFunc(groups, func(r rune) bool { return r == ',' || r == ':' }) {
+		g, list := term, &include
+		switch term[0] {
+		case '&':
+			g, list = term[1:], &require
+		case '!':
+			g, list = term[1:], &exclude
+		}
+		if len(g) == 0 {
+			return nil, fmt.Errorf("missing group name in %q", term)
+		}
+		if !inv.hasGroup(g) {
+			return nil, fmt.Errorf("no hosts in group %q", g)
+		}
+		*list = append(*list, g)
+	}
+	if len(include) == 0 {
+		include = []string{"all"}
+	}
+
+	var hosts []Host
+	for _, h := range inv.Hosts {
+		if h.inAny(include) && h.inAll(require) && !h.inAny(exclude) {
+			hosts = append(hosts, h)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no hosts match %q", groups)
+	}
+	return hosts, nil
+}
+
+func (inv *Inventory) hasGroup(group string) bool {
+	for _, h := range inv.Hosts {
+		if h.InGroup(group) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h Host) inAny(groups []string) bool {
+	for _, g := range groups {
+		if h.InGroup(g) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h Host) inAll(groups []string) bool {
+	for _, g := range groups {
+		if !h.InGroup(g) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsPattern reports whether a host argument is a glob
+func IsPattern(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// Match returns the hosts whose name matches the glob
+func (inv *Inventory) Match(pattern string) []Host {
+	var hosts []Host
+	for _, h := range inv.Hosts {
+		if ok, err := path.Match(pattern, h.Name); ok && err == nil {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// LoadList reads a plain host list, one [user@]host[:port] per line, with
+// blank lines and # comments ignored
+func LoadList(file string) ([]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var hosts []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "#"); i != -1 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); len(line) > 0 {
+			hosts = append(hosts, line)
+		}
+	}
+	return hosts, nil
+}
+
+// DefaultAnsibleInventory is where Ansible itself looks without -i
+func DefaultAnsibleInventory() string {
+	if env := os.Getenv("ANSIBLE_INVENTORY"); len(env) > 0 {
+		// A comma separated list, the first entry will do
+		return strings.Split(env, ",")[0]
+	}
+	return "/etc/ansible/hosts"
+}
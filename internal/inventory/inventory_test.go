@@ -0,0 +1,55 @@
+package inventory
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSelect(t *testing.T) {
+	inv := New()
+	inv.Add(Host{Name: "web1", Groups: []string{"web", "prod"}})
+	inv.Add(Host{Name: "web2", Groups: []string{"web", "staging"}})
+	inv.Add(Host{Name: "db1", Groups: []string{"db", "prod"}})
+	inv.Add(Host{Name: "db2", Groups: []string{"db", "staging"}})
+
+	tests := []struct {
+		groups string
+		want   []string
+		err    string
+	}{
+		{groups: "web", want: []string{"web1", "web2"}},
+		{groups: "web,db", want: []string{"web1", "web2", "db1", "db2"}},
+		{groups: "web:prod", want: []string{"web1", "web2", "db1"}},
+		{groups: "web:&prod", want: []string{"web1"}},
+		{groups: "web:!staging", want: []string{"web1"}},
+		{groups: "all:!web", want: []string{"db1", "db2"}},
+		{groups: "!web", want: []string{"db1", "db2"}},
+		{groups: "&staging,!db", want: []string{"web2"}},
+		{groups: "web:db:&prod:!db", want: []string{"web1"}},
+		{groups: "nope", err: `no hosts in group "nope"`},
+		{groups: "web:!nope", err: `no hosts in group "nope"`},
+		{groups: "web:!", err: `missing group name in "!"`},
+		{groups: "web:&db", err: `no hosts match "web:&db"`},
+	}
+	for _, tt := range tests {
+		hosts, err := inv.Select(tt.groups)
+		if len(tt.err) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Select(%q) error = %v, want %q", tt.groups, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Select(%q) error = %v", tt.groups, err)
+			continue
+		}
+		var names []string
+		for _, h := range hosts {
+			names = append(names, h.Name)
+		}
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("Select(%q) = %v, want %v", tt.groups, names, tt.want)
+		}
+	}
+}
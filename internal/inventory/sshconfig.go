@@ -0,0 +1,27 @@
+package inventory
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/0x0BSoD/rtop/internal/stats"
+)
+
+// FromSshConfig lists the Host entries of the parsed ssh_config that name a
+// single machine, leaving out wildcards and negations. They are put in the
+// "ssh_config" group.
+func FromSshConfig() *Inventory {
+	var names []string
+	for name := range stats.HostInfo {
+		if !strings.ContainsAny(name, "*?!") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	inv := New()
+	for _, name := range names {
+		inv.Add(Host{Name: name, Groups: []string{"ssh_config"}})
+	}
+	return inv
+}
@@ -0,0 +1,182 @@
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CSV appends a row per host and round to a file. The columns come from the
+// first snapshot, or from the header of an existing file, and new ones are
+// added at the end when mounts or interfaces appear.
+type CSV struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	columns []string
+	index   map[string]int
+}
+
+// OpenCSV opens path for appending, creating it if needed
+func OpenCSV(path string) (*CSV, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	c := &CSV{path: path, file: file, index: make(map[string]int)}
+
+	header, err := csv.NewReader(file).Read()
+	if err != nil && err != io.EOF {
+		file.Close()
+		return nil, fmt.Errorf("failed to read the header of %s: %w", path, err)
+	}
+	for _, name := range header {
+		c.index[name] = len(c.columns)
+		c.columns = append(c.columns, name)
+	}
+	return c, nil
+}
+
+func (c *CSV) Close() error {
+	return c.file.Close()
+}
+
+type field struct {
+	name  string
+	value string
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func formatUint(v uint64) string {
+	return strconv.FormatUint(v, 10)
+}
+
+// flatten lists the values of a snapshot in column order. Sizes are in bytes,
+// rates in bytes per second and CPU shares in percent.
+func flatten(snap Snapshot) []field {
+	fields := []field{
+		{"time", snap.Timestamp.Format(time.RFC3339)},
+		{"host", snap.Host},
+		{"hostname", snap.Hostname},
+		{"uptime", formatFloat(snap.Uptime)},
+		{"load.1", formatFloat(snap.Load[0])},
+		{"load.5", formatFloat(snap.Load[1])},
+		{"load.15", formatFloat(snap.Load[2])},
+		{"procs.running", strconv.Itoa(snap.Processes.Running)},
+		{"procs.total", strconv.Itoa(snap.Processes.Total)},
+		{"cpu.user", formatFloat(snap.CPU.User)},
+		{"cpu.nice", formatFloat(snap.CPU.Nice)},
+		{"cpu.system", formatFloat(snap.CPU.System)},
+		{"cpu.idle", formatFloat(snap.CPU.Idle)},
+		{"cpu.iowait", formatFloat(snap.CPU.Iowait)},
+		{"cpu.irq", formatFloat(snap.CPU.Irq)},
+		{"cpu.softirq", formatFloat(snap.CPU.SoftIrq)},
+		{"cpu.steal", formatFloat(snap.CPU.Steal)},
+		{"cpu.guest", formatFloat(snap.CPU.Guest)},
+		{"mem.total", formatUint(snap.Memory.Total)},
+		{"mem.used", formatUint(snap.Memory.Used)},
+		{"mem.free", formatUint(snap.Memory.Free)},
+		{"mem.buffers", formatUint(snap.Memory.Buffers)},
+		{"mem.cached", formatUint(snap.Memory.Cached)},
+		{"swap.total", formatUint(snap.Memory.SwapTotal)},
+		{"swap.free", formatUint(snap.Memory.SwapFree)},
+	}
+	for _, fs := range snap.Filesystems {
+		fields = append(fields,
+			field{"fs." + fs.MountPoint + ".used", formatUint(fs.Used)},
+			field{"fs." + fs.MountPoint + ".total", formatUint(fs.Total)},
+		)
+	}
+	for _, intf := range snap.Interfaces {
+		fields = append(fields,
+			field{"net." + intf.Name + ".rx_rate", formatFloat(intf.RxRate)},
+			field{"net." + intf.Name + ".tx_rate", formatFloat(intf.TxRate)},
+		)
+	}
+	return fields
+}
+
+// Write appends a row for snap
+func (c *CSV) Write(snap Snapshot) error {
+	fields := flatten(snap)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	added := false
+	for _, fl := range fields {
+		if _, ok := c.index[fl.name]; !ok {
+			c.index[fl.name] = len(c.columns)
+			c.columns = append(c.columns, fl.name)
+			added = true
+		}
+	}
+	if added {
+		if err := c.rewriteHeader(); err != nil {
+			return err
+		}
+	}
+
+	row := make([]string, len(c.columns))
+	for _, fl := range fields {
+		row[c.index[fl.name]] = fl.value
+	}
+	w := csv.NewWriter(c.file)
+	w.Write(row)
+	w.Flush()
+	return w.Error()
+}
+
+// rewriteHeader replaces the file with one carrying the current columns,
+// padding the rows already written
+func (c *CSV) rewriteHeader() error {
+	if _, err := c.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r := csv.NewReader(c.file)
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", c.path, err)
+	}
+	if len(rows) > 0 {
+		rows = rows[1:]
+	}
+
+	tmp := c.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to rewrite %s: %w", c.path, err)
+	}
+	w := csv.NewWriter(file)
+	w.Write(c.columns)
+	for _, row := range rows {
+		for len(row) < len(c.columns) {
+			row = append(row, "")
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to rewrite %s: %w", c.path, err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to rewrite %s: %w", c.path, err)
+	}
+
+	// The new file is open for writing at its end, like with O_APPEND
+	c.file.Close()
+	c.file = file
+	return nil
+}
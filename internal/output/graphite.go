@@ -0,0 +1,94 @@
+package output
+
+import (
+	"strconv"
+	"strings"
+)
+
+// OpenGraphite writes the Graphite plaintext protocol to a target as accepted
+// by openTarget. Metrics are named rtop.<host>.<metric>.
+func OpenGraphite(target string) (Sink, error) {
+	w, err := openTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	return &lineSink{w: w, format: formatGraphite}, nil
+}
+
+// graphiteNode makes s usable as one node of a metric path, / as root
+func graphiteNode(s string) string {
+	s = strings.Trim(s, "/")
+	if len(s) == 0 {
+		return "root"
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+func formatGraphite(sb *strings.Builder, snap Snapshot) {
+	ts := " " + strconv.FormatInt(snap.Timestamp.Unix(), 10) + "\n"
+	prefix := "rtop." + graphiteNode(snap.Host) + "."
+	metric := func(path string, v float64) {
+		sb.WriteString(prefix + path + " " + strconv.FormatFloat(v, 'f', -1, 64) + ts)
+	}
+
+	metric("uptime", snap.Uptime)
+	metric("load.1", snap.Load[0])
+	metric("load.5", snap.Load[1])
+	metric("load.15", snap.Load[2])
+	metric("procs.running", float64(snap.Processes.Running))
+	metric("procs.total", float64(snap.Processes.Total))
+
+	cpu := snap.CPU
+	metric("cpu.cores", float64(cpu.Cores))
+	metric("cpu.user", cpu.User)
+	metric("cpu.nice", cpu.Nice)
+	metric("cpu.system", cpu.System)
+	metric("cpu.idle", cpu.Idle)
+	metric("cpu.iowait", cpu.Iowait)
+	metric("cpu.irq", cpu.Irq)
+	metric("cpu.softirq", cpu.SoftIrq)
+	metric("cpu.steal", cpu.Steal)
+	metric("cpu.guest", cpu.Guest)
+
+	mem := snap.Memory
+	metric("mem.total", float64(mem.Total))
+	metric("mem.used", float64(mem.Used))
+	metric("mem.free", float64(mem.Free))
+	metric("mem.buffers", float64(mem.Buffers))
+	metric("mem.cached", float64(mem.Cached))
+	metric("swap.total", float64(mem.SwapTotal))
+	metric("swap.free", float64(mem.SwapFree))
+
+	for _, fs := range snap.Filesystems {
+		node := "fs." + graphiteNode(fs.MountPoint) + "."
+		metric(node+"total", float64(fs.Total))
+		metric(node+"used", float64(fs.Used))
+		metric(node+"free", float64(fs.Free))
+	}
+
+	for _, intf := range snap.Interfaces {
+		node := "net." + graphiteNode(intf.Name) + "."
+		metric(node+"rx", float64(intf.Rx))
+		metric(node+"tx", float64(intf.Tx))
+		metric(node+"rx_rate", intf.RxRate)
+		metric(node+"tx_rate", intf.TxRate)
+	}
+
+	var cgroups func([]CgroupSnapshot)
+	cgroups = func(list []CgroupSnapshot) {
+		for _, cg := range list {
+			node := "cgroup." + graphiteNode(strings.TrimPrefix(cg.Path, "/sys/fs/cgroup")) + "."
+			metric(node+"cpu_usage", cg.CPUUsage)
+			metric(node+"memory", float64(cg.Memory))
+			metric(node+"io_read", float64(cg.IoRead))
+			metric(node+"io_write", float64(cg.IoWrite))
+			cgroups(cg.Children)
+		}
+	}
+	cgroups(snap.Cgroups)
+}
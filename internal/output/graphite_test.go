@@ -0,0 +1,52 @@
+package output
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGraphiteNode(t *testing.T) {
+	for s, want := range map[string]string{
+		"/":              "root",
+		"":               "root",
+		"/var/lib":       "var_lib",
+		"web1.example":   "web1_example",
+		"eth0":           "eth0",
+		"br-lan":         "br-lan",
+		"a b;c=d":        "a_b_c_d",
+		"system.slice/x": "system_slice_x",
+	} {
+		if got := graphiteNode(s); got != want {
+			t.Errorf("graphiteNode(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestFormatGraphite(t *testing.T) {
+	snap := Snapshot{
+		Host:        "web1.example.com",
+		Timestamp:   time.Unix(1700000000, 999),
+		Filesystems: []FilesystemSnapshot{{MountPoint: "/", Used: 5}},
+		Interfaces:  []InterfaceSnapshot{{Name: "eth0", RxRate: 2.5}},
+		Cgroups:     []CgroupSnapshot{{Path: "/sys/fs/cgroup/system.slice/nginx.service", Memory: 9}},
+	}
+	var sb strings.Builder
+	formatGraphite(&sb, snap)
+	got := sb.String()
+	for _, line := range []string{
+		"rtop.web1_example_com.load.1 0 1700000000",
+		"rtop.web1_example_com.fs.root.used 5 1700000000",
+		"rtop.web1_example_com.net.eth0.rx_rate 2.5 1700000000",
+		"rtop.web1_example_com.cgroup.system_slice_nginx_service.memory 9 1700000000",
+	} {
+		if !strings.Contains(got, line+"\n") {
+			t.Errorf("missing %s in\n%s", line, got)
+		}
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(got, "\n"), "\n") {
+		if n := len(strings.Fields(line)); n != 3 {
+			t.Errorf("line with %d fields: %s", n, line)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package output
+
+import (
+	"strconv"
+	"strings"
+)
+
+var (
+	influxTagEscaper         = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+	influxFieldStringEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+// OpenInflux writes InfluxDB line protocol to a target as accepted by
+// openTarget, such as the socket_listener input of Telegraf
+func OpenInflux(target string) (Sink, error) {
+	w, err := openTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	return &lineSink{w: w, format: formatInflux}, nil
+}
+
+// influxLine builds a single point, fields are added in order
+type influxLine struct {
+	sb     *strings.Builder
+	fields int
+}
+
+func newInfluxLine(sb *strings.Builder, measurement string, tags ...string) *influxLine {
+	sb.WriteString(measurement)
+	for i := 0; i+1 < len(tags); i += 2 {
+		if len(tags[i+1]) == 0 {
+			continue
+		}
+		sb.WriteString("," + tags[i] + "=" + influxTagEscaper.Replace(tags[i+1]))
+	}
+	sb.WriteString(" ")
+	return &influxLine{sb: sb}
+}
+
+func (l *influxLine) field(name, value string) *influxLine {
+	if l.fields > 0 {
+		l.sb.WriteString(",")
+	}
+	l.sb.WriteString(name + "=" + value)
+	l.fields++
+	return l
+}
+
+func (l *influxLine) float(name string, v float64) *influxLine {
+	return l.field(name, strconv.FormatFloat(v, 'f', -1, 64))
+}
+
+func (l *influxLine) int(name string, v int64) *influxLine {
+	return l.field(name, strconv.FormatInt(v, 10)+"i")
+}
+
+func (l *influxLine) uint(name string, v uint64) *influxLine {
+	return l.field(name, strconv.FormatUint(v, 10)+"i")
+}
+
+func (l *influxLine) string(name, v string) *influxLine {
+	return l.field(name, `"`+influxFieldStringEscaper.Replace(v)+`"`)
+}
+
+func (l *influxLine) end(ts string) {
+	l.sb.WriteString(" " + ts + "\n")
+}
+
+func formatInflux(sb *strings.Builder, snap Snapshot) {
+	ts := strconv.FormatInt(snap.Timestamp.UnixNano(), 10)
+	host := snap.Host
+
+	newInfluxLine(sb, "rtop_system", "host", host).
+		string("hostname", snap.Hostname).
+		float("uptime", snap.Uptime).
+		float("load1", snap.Load[0]).
+		float("load5", snap.Load[1]).
+		float("load15", snap.Load[2]).
+		int("procs_running", int64(snap.Processes.Running)).
+		int("procs_total", int64(snap.Processes.Total)).
+		end(ts)
+
+	cpu := snap.CPU
+	newInfluxLine(sb, "rtop_cpu", "host", host).
+		int("cores", int64(cpu.Cores)).
+		float("user", cpu.User).
+		float("nice", cpu.Nice).
+		float("system", cpu.System).
+		float("idle", cpu.Idle).
+		float("iowait", cpu.Iowait).
+		float("irq", cpu.Irq).
+		float("softirq", cpu.SoftIrq).
+		float("steal", cpu.Steal).
+		float("guest", cpu.Guest).
+		end(ts)
+
+	mem := snap.Memory
+	newInfluxLine(sb, "rtop_mem", "host", host).
+		uint("total", mem.Total).
+		uint("used", mem.Used).
+		uint("free", mem.Free).
+		uint("buffers", mem.Buffers).
+		uint("cached", mem.Cached).
+		uint("swap_total", mem.SwapTotal).
+		uint("swap_free", mem.SwapFree).
+		end(ts)
+
+	for _, fs := range snap.Filesystems {
+		newInfluxLine(sb, "rtop_disk", "host", host, "device", fs.Device, "path", fs.MountPoint).
+			uint("total", fs.Total).
+			uint("used", fs.Used).
+			uint("free", fs.Free).
+			end(ts)
+	}
+
+	for _, intf := range snap.Interfaces {
+		newInfluxLine(sb, "rtop_net", "host", host, "interface", intf.Name).
+			uint("rx", intf.Rx).
+			uint("tx", intf.Tx).
+			float("rx_rate", intf.RxRate).
+			float("tx_rate", intf.TxRate).
+			end(ts)
+	}
+
+	var cgroups func([]CgroupSnapshot)
+	cgroups = func(list []CgroupSnapshot) {
+		for _, cg := range list {
+			newInfluxLine(sb, "rtop_cgroup", "host", host, "path", cg.Path).
+				float("cpu_usage", cg.CPUUsage).
+				int("memory", int64(cg.Memory)).
+				int("memory_limit", int64(cg.MemoryLimit)).
+				int("io_read", int64(cg.IoRead)).
+				int("io_write", int64(cg.IoWrite)).
+				end(ts)
+			cgroups(cg.Children)
+		}
+	}
+	cgroups(snap.Cgroups)
+}
@@ -0,0 +1,75 @@
+package output
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInfluxLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(sb *strings.Builder)
+		want  string
+	}{
+		{
+			name: "plain",
+			build: func(sb *strings.Builder) {
+				newInfluxLine(sb, "rtop_net", "host", "web1", "interface", "eth0").uint("rx", 42).float("rx_rate", 1.5).end("1")
+			},
+			want: "rtop_net,host=web1,interface=eth0 rx=42i,rx_rate=1.5 1\n",
+		},
+		{
+			name: "tags escaped",
+			build: func(sb *strings.Builder) {
+				newInfluxLine(sb, "rtop_disk", "host", "web 1", "path", `/mnt/a,b=c`).int("used", -1).end("2")
+			},
+			want: `rtop_disk,host=web\ 1,path=/mnt/a\,b\=c used=-1i 2` + "\n",
+		},
+		{
+			name: "empty tags left out",
+			build: func(sb *strings.Builder) {
+				newInfluxLine(sb, "rtop_disk", "host", "web1", "device", "", "path", "/").uint("free", 0).end("3")
+			},
+			want: "rtop_disk,host=web1,path=/ free=0i 3\n",
+		},
+		{
+			name: "string field escaped",
+			build: func(sb *strings.Builder) {
+				newInfluxLine(sb, "rtop_system", "host", "web1").string("hostname", `say "hi" \ bye`).end("4")
+			},
+			want: `rtop_system,host=web1 hostname="say \"hi\" \\ bye" 4` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		var sb strings.Builder
+		tt.build(&sb)
+		if got := sb.String(); got != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFormatInflux(t *testing.T) {
+	snap := Snapshot{
+		Host:      "web1",
+		Timestamp: time.Unix(1700000000, 5),
+		Cgroups: []CgroupSnapshot{{Path: "/sys/fs/cgroup/system.slice", Children: []CgroupSnapshot{
+			{Path: "/sys/fs/cgroup/system.slice/nginx.service", Memory: 7},
+		}}},
+	}
+	var sb strings.Builder
+	formatInflux(&sb, snap)
+	lines := strings.Split(strings.TrimSuffix(sb.String(), "\n"), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("%d lines, want 5:\n%s", len(lines), sb.String())
+	}
+	for _, line := range lines {
+		if !strings.HasSuffix(line, " 1700000000000000005") {
+			t.Errorf("line not stamped in nanoseconds: %s", line)
+		}
+	}
+	if want := "rtop_cgroup,host=web1,path=/sys/fs/cgroup/system.slice/nginx.service cpu_usage=0,memory=7i,"; !strings.HasPrefix(lines[4], want) {
+		t.Errorf("nested cgroup written as %s", lines[4])
+	}
+}
@@ -0,0 +1,232 @@
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/0x0BSoD/rtop/internal/stats"
+)
+
+// SchemaVersion is bumped whenever a field of Snapshot is renamed, removed or
+// changes meaning. Adding fields does not change it.
+const SchemaVersion = 1
+
+// Snapshot is one round of stats from a host as written by --output json, one
+// object per line. Sizes are in bytes, rates in bytes per second, CPU shares
+// in percent and durations in seconds.
+type Snapshot struct {
+	Version   int       `json:"version"`
+	Host      string    `json:"host"` // as given on the command line
+	Timestamp time.Time `json:"timestamp"`
+	// Reconnecting is set while the host is unreachable, the stats are then
+	// those collected at LastUpdate
+	Reconnecting bool                     `json:"reconnecting"`
+	Error        string                   `json:"error,omitempty"` // why the host is unreachable
+	LastUpdate   *time.Time               `json:"last_update,omitempty"`
+	Hostname     string                   `json:"hostname"`
+	Uptime       float64                  `json:"uptime_seconds"`
+	Load         [3]float64               `json:"load"` // 1, 5 and 15 minutes
+	Processes    ProcessesSnapshot        `json:"processes"`
+	CPU          CPUSnapshot              `json:"cpu"`
+	Memory       MemorySnapshot           `json:"memory"`
+	Filesystems  []FilesystemSnapshot     `json:"filesystems"`
+	Interfaces   []InterfaceSnapshot      `json:"interfaces"` // sorted by name
+	Cgroups      []CgroupSnapshot         `json:"cgroups,omitempty"`
+	Errors       map[string]CollectorFail `json:"errors,omitempty"` // by collector
+	// Degraded are the privileged collectors running without sudo, with why
+	Degraded map[string]string `json:"degraded,omitempty"`
+}
+
+type ProcessesSnapshot struct {
+	Running int `json:"running"`
+	Total   int `json:"total"`
+}
+
+type CPUSnapshot struct {
+	Cores   int     `json:"cores"`
+	User    float64 `json:"user_percent"`
+	Nice    float64 `json:"nice_percent"`
+	System  float64 `json:"system_percent"`
+	Idle    float64 `json:"idle_percent"`
+	Iowait  float64 `json:"iowait_percent"`
+	Irq     float64 `json:"irq_percent"`
+	SoftIrq float64 `json:"softirq_percent"`
+	Steal   float64 `json:"steal_percent"`
+	Guest   float64 `json:"guest_percent"`
+}
+
+type MemorySnapshot struct {
+	Total     uint64 `json:"total_bytes"`
+	Used      uint64 `json:"used_bytes"` // neither free nor buffers or cache
+	Free      uint64 `json:"free_bytes"`
+	Buffers   uint64 `json:"buffers_bytes"`
+	Cached    uint64 `json:"cached_bytes"`
+	SwapTotal uint64 `json:"swap_total_bytes"`
+	SwapFree  uint64 `json:"swap_free_bytes"`
+}
+
+type FilesystemSnapshot struct {
+	Device     string `json:"device"`
+	MountPoint string `json:"mount_point"`
+	Used       uint64 `json:"used_bytes"`
+	Free       uint64 `json:"free_bytes"`
+	Total      uint64 `json:"total_bytes"`
+}
+
+type InterfaceSnapshot struct {
+	Name   string  `json:"name"`
+	IPv4   string  `json:"ipv4,omitempty"`
+	IPv6   string  `json:"ipv6,omitempty"`
+	Rx     uint64  `json:"rx_bytes"`
+	Tx     uint64  `json:"tx_bytes"`
+	RxRate float64 `json:"rx_bytes_per_second"`
+	TxRate float64 `json:"tx_bytes_per_second"`
+}
+
+type CgroupSnapshot struct {
+	Path        string           `json:"path"`
+	CPUUsage    float64          `json:"cpu_usage_seconds"`
+	Memory      int              `json:"memory_bytes"`
+	MemoryLimit int              `json:"memory_limit_bytes,omitempty"` // absent when unlimited
+	IoRead      int              `json:"io_read_bytes"`
+	IoWrite     int              `json:"io_write_bytes"`
+	OomKills    int              `json:"oom_kills"`
+	Children    []CgroupSnapshot `json:"children,omitempty"`
+}
+
+// CollectorFail is a collector that failed in the round. Unsupported ones were
+// not run because the host lacks what they need.
+type CollectorFail struct {
+	Reason      string `json:"reason"`
+	Unsupported bool   `json:"unsupported,omitempty"`
+}
+
+// NewSnapshot converts the last stats collected by f
+func NewSnapshot(f *stats.SshFetcher, at time.Time) Snapshot {
+	st := f.Stats
+	status := f.Status()
+	snap := Snapshot{
+		Version:      SchemaVersion,
+		Host:         f.Name,
+		Timestamp:    at,
+		Reconnecting: status.Reconnecting,
+		Hostname:     st.Hostname,
+		Uptime:       st.Uptime.Seconds(),
+		CPU: CPUSnapshot{
+			Cores:   st.Cores,
+			User:    widen(st.CPU.User),
+			Nice:    widen(st.CPU.Nice),
+			System:  widen(st.CPU.System),
+			Idle:    widen(st.CPU.Idle),
+			Iowait:  widen(st.CPU.Iowait),
+			Irq:     widen(st.CPU.Irq),
+			SoftIrq: widen(st.CPU.SoftIrq),
+			Steal:   widen(st.CPU.Steal),
+			Guest:   widen(st.CPU.Guest),
+		},
+		Memory: MemorySnapshot{
+			Total:     st.MemTotal,
+			Used:      st.MemUsed(),
+			Free:      st.MemFree,
+			Buffers:   st.MemBuffers,
+			Cached:    st.MemCached,
+			SwapTotal: st.SwapTotal,
+			SwapFree:  st.SwapFree,
+		},
+		Filesystems: make([]FilesystemSnapshot, 0, len(st.FSInfos)),
+		Interfaces:  make([]InterfaceSnapshot, 0, len(st.NetIntf)),
+	}
+	if status.Reconnecting {
+		if status.LastError != nil {
+			snap.Error = status.LastError.Error()
+		}
+		if !status.LastUpdate.IsZero() {
+			snap.LastUpdate = &status.LastUpdate
+		}
+	}
+	for i, load := range []string{st.Load1, st.Load5, st.Load10} {
+		snap.Load[i], _ = strconv.ParseFloat(load, 64)
+	}
+	snap.Processes.Running, _ = strconv.Atoi(st.RunningProcs)
+	snap.Processes.Total, _ = strconv.Atoi(st.TotalProcs)
+
+	for _, fs := range st.FSInfos {
+		snap.Filesystems = append(snap.Filesystems, FilesystemSnapshot{
+			Device:     fs.Device,
+			MountPoint: fs.MountPoint,
+			Used:       fs.Used,
+			Free:       fs.Free,
+			Total:      fs.Used + fs.Free,
+		})
+	}
+
+	for name, intf := range st.NetIntf {
+		snap.Interfaces = append(snap.Interfaces, InterfaceSnapshot{
+			Name:   name,
+			IPv4:   intf.IPv4,
+			IPv6:   intf.IPv6,
+			Rx:     intf.Rx,
+			Tx:     intf.Tx,
+			RxRate: intf.RxRate,
+			TxRate: intf.TxRate,
+		})
+	}
+	sort.Slice(snap.Interfaces, func(i, j int) bool {
+		return snap.Interfaces[i].Name < snap.Interfaces[j].Name
+	})
+
+	snap.Cgroups = cgroupSnapshots(st.Cgroups)
+	if len(f.Degraded) > 0 {
+		snap.Degraded = make(map[string]string, len(f.Degraded))
+		for name, reason := range f.Degraded {
+			snap.Degraded[name] = reason
+		}
+	}
+
+	if len(st.Errors) > 0 {
+		snap.Errors = make(map[string]CollectorFail, len(st.Errors))
+		for name, err := range st.Errors {
+			snap.Errors[name] = CollectorFail{
+				Reason:      err.Reason(),
+				Unsupported: errors.Is(err, stats.ErrUnsupported),
+			}
+		}
+	}
+	return snap
+}
+
+// widen converts a CPU share without the noise float64 shows in the last
+// digits of a float32
+func widen(v float32) float64 {
+	f, _ := strconv.ParseFloat(strconv.FormatFloat(float64(v), 'g', -1, 32), 64)
+	return f
+}
+
+func cgroupSnapshots(cgroups []*stats.Cgroup) []CgroupSnapshot {
+	if len(cgroups) == 0 {
+		return nil
+	}
+	snaps := make([]CgroupSnapshot, 0, len(cgroups))
+	for _, cg := range cgroups {
+		snaps = append(snaps, CgroupSnapshot{
+			Path:        cg.Path,
+			CPUUsage:    cg.CpuUsage,
+			Memory:      cg.MemoryUsageCurrent,
+			MemoryLimit: cg.MemoryUsageLimit,
+			IoRead:      cg.IoReadBytes,
+			IoWrite:     cg.IoWriteBytes,
+			OomKills:    cg.OomKills,
+			Children:    cgroupSnapshots(cg.Childs),
+		})
+	}
+	return snaps
+}
+
+// JSON writes the last stats collected by f as a single line of JSON
+func JSON(w io.Writer, f *stats.SshFetcher, at time.Time) error {
+	return json.NewEncoder(w).Encode(NewSnapshot(f, at))
+}
@@ -0,0 +1,321 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// otlpTimeout bounds a single export
+const otlpTimeout = 10 * time.Second
+
+// OTLP pushes every snapshot to an OpenTelemetry collector over OTLP/HTTP,
+// JSON encoded, using the system.* semantic conventions where there is one
+type OTLP struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	mu      sync.Mutex
+	starts  map[string]otlpStart // by host
+}
+
+// otlpStart is the boot time of a host, where its cumulative sums start. It is
+// worked out once, as uptimes read remotely jitter, and again only when the
+// uptime goes backwards, after a reboot.
+type otlpStart struct {
+	at     time.Time
+	uptime float64 // the last one seen
+}
+
+// OpenOTLP exports to endpoint, the base URL of the collector such as
+// http://localhost:4318 or the full URL of its /v1/metrics. Headers in
+// $OTEL_EXPORTER_OTLP_HEADERS, as k=v,k=v, are sent along.
+func OpenOTLP(endpoint string) (Sink, error) {
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		return nil, fmt.Errorf("bad OTLP endpoint %s, want an http:// or https:// URL", endpoint)
+	}
+	url := endpoint
+	if !strings.HasSuffix(url, "/v1/metrics") {
+		url = strings.TrimSuffix(url, "/") + "/v1/metrics"
+	}
+
+	headers := make(map[string]string)
+	for _, kv := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return &OTLP{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{Timeout: otlpTimeout},
+		starts:  make(map[string]otlpStart),
+	}, nil
+}
+
+// start returns the boot time of the host of snap
+func (o *OTLP) start(snap Snapshot) time.Time {
+	boot := snap.Timestamp.Add(-time.Duration(snap.Uptime * float64(time.Second)))
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	start, ok := o.starts[snap.Host]
+	if snap.Uptime == 0 {
+		// The uptime collector failed
+		if ok {
+			return start.at
+		}
+		return boot
+	}
+	if !ok || snap.Uptime < start.uptime {
+		start.at = boot
+	}
+	start.uptime = snap.Uptime
+	o.starts[snap.Host] = start
+	return start.at
+}
+
+func (o *OTLP) Write(snap Snapshot) error {
+	body, err := json.Marshal(otlpRequest(snap, o.start(snap)))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, o.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range o.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export to %s: %w", o.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to export to %s: %s: %s", o.url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func (o *OTLP) Close() error {
+	o.client.CloseIdleConnections()
+	return nil
+}
+
+// The JSON mapping of ExportMetricsServiceRequest, only as much of it as rtop
+// needs. 64 bit integers are strings, as protobuf JSON has them.
+
+type otlpAttribute struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+type otlpPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsDouble          *float64        `json:"asDouble,omitempty"`
+	AsInt             string          `json:"asInt,omitempty"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpPoint `json:"dataPoints"`
+	AggregationTemporality int         `json:"aggregationTemporality"`
+	IsMonotonic            bool        `json:"isMonotonic"`
+}
+
+type otlpMetric struct {
+	Name  string     `json:"name"`
+	Unit  string     `json:"unit"`
+	Gauge *otlpGauge `json:"gauge,omitempty"`
+	Sum   *otlpSum   `json:"sum,omitempty"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   map[string]string `json:"scope"`
+	Metrics []*otlpMetric     `json:"metrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     map[string][]otlpAttribute `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics         `json:"scopeMetrics"`
+}
+
+type otlpExport struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+const otlpCumulative = 2 // AGGREGATION_TEMPORALITY_CUMULATIVE
+
+func otlpString(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: map[string]any{"stringValue": value}}
+}
+
+// otlpMetrics collects the metrics of a snapshot, keeping the order in which
+// they first appear
+type otlpMetrics struct {
+	list   []*otlpMetric
+	byName map[string]*otlpMetric
+	now    string
+	start  string // boot time, the start of every cumulative sum
+}
+
+func (m *otlpMetrics) metric(name, unit string, sum, monotonic bool) *otlpMetric {
+	if metric, ok := m.byName[name]; ok {
+		return metric
+	}
+	metric := &otlpMetric{Name: name, Unit: unit}
+	if sum {
+		metric.Sum = &otlpSum{AggregationTemporality: otlpCumulative, IsMonotonic: monotonic}
+	} else {
+		metric.Gauge = &otlpGauge{}
+	}
+	m.byName[name] = metric
+	m.list = append(m.list, metric)
+	return metric
+}
+
+func (m *otlpMetrics) add(metric *otlpMetric, point otlpPoint, attrs ...string) {
+	point.TimeUnixNano = m.now
+	for i := 0; i+1 < len(attrs); i += 2 {
+		point.Attributes = append(point.Attributes, otlpString(attrs[i], attrs[i+1]))
+	}
+	if metric.Sum != nil {
+		point.StartTimeUnixNano = m.start
+		metric.Sum.DataPoints = append(metric.Sum.DataPoints, point)
+	} else {
+		metric.Gauge.DataPoints = append(metric.Gauge.DataPoints, point)
+	}
+}
+
+func (m *otlpMetrics) gauge(name, unit string, v float64, attrs ...string) {
+	m.add(m.metric(name, unit, false, false), otlpPoint{AsDouble: &v}, attrs...)
+}
+
+// upDown is a non monotonic sum, for amounts like memory used
+func (m *otlpMetrics) upDown(name, unit string, v uint64, attrs ...string) {
+	m.add(m.metric(name, unit, true, false), otlpPoint{AsInt: strconv.FormatUint(v, 10)}, attrs...)
+}
+
+func (m *otlpMetrics) counter(name, unit string, v uint64, attrs ...string) {
+	m.add(m.metric(name, unit, true, true), otlpPoint{AsInt: strconv.FormatUint(v, 10)}, attrs...)
+}
+
+func (m *otlpMetrics) counterDouble(name, unit string, v float64, attrs ...string) {
+	m.add(m.metric(name, unit, true, true), otlpPoint{AsDouble: &v}, attrs...)
+}
+
+// otlpRequest converts snap, with cumulative sums starting at start
+func otlpRequest(snap Snapshot, start time.Time) otlpExport {
+	m := &otlpMetrics{
+		byName: make(map[string]*otlpMetric),
+		now:    strconv.FormatInt(snap.Timestamp.UnixNano(), 10),
+		start:  strconv.FormatInt(start.UnixNano(), 10),
+	}
+
+	m.gauge("system.uptime", "s", snap.Uptime)
+	m.gauge("system.cpu.load_average.1m", "{thread}", snap.Load[0])
+	m.gauge("system.cpu.load_average.5m", "{thread}", snap.Load[1])
+	m.gauge("system.cpu.load_average.15m", "{thread}", snap.Load[2])
+	m.upDown("system.process.count", "{process}", uint64(snap.Processes.Running), "process.status", "running")
+
+	m.upDown("system.cpu.logical.count", "{cpu}", uint64(snap.CPU.Cores))
+	for _, mode := range []struct {
+		name  string
+		value float64
+	}{
+		{"user", snap.CPU.User},
+		{"nice", snap.CPU.Nice},
+		{"system", snap.CPU.System},
+		{"idle", snap.CPU.Idle},
+		{"iowait", snap.CPU.Iowait},
+		{"interrupt", snap.CPU.Irq},
+		{"softirq", snap.CPU.SoftIrq},
+		{"steal", snap.CPU.Steal},
+		{"guest", snap.CPU.Guest},
+	} {
+		m.gauge("system.cpu.utilization", "1", mode.value/100, "cpu.mode", mode.name)
+	}
+
+	// The states add up to the limit, used leaves out buffers and cache
+	mem := snap.Memory
+	for _, state := range []struct {
+		name  string
+		value uint64
+	}{
+		{"used", mem.Used},
+		{"free", mem.Free},
+		{"buffers", mem.Buffers},
+		{"cached", mem.Cached},
+	} {
+		m.upDown("system.memory.usage", "By", state.value, "system.memory.state", state.name)
+	}
+	m.upDown("system.memory.limit", "By", mem.Total)
+	if mem.Total > 0 {
+		m.gauge("system.memory.utilization", "1", float64(mem.Used)/float64(mem.Total), "system.memory.state", "used")
+	}
+	m.upDown("system.paging.usage", "By", mem.SwapTotal-mem.SwapFree, "system.paging.state", "used")
+	m.upDown("system.paging.usage", "By", mem.SwapFree, "system.paging.state", "free")
+
+	for _, fs := range snap.Filesystems {
+		attrs := []string{"system.device", fs.Device, "system.filesystem.mountpoint", fs.MountPoint}
+		m.upDown("system.filesystem.usage", "By", fs.Used, append(attrs, "system.filesystem.state", "used")...)
+		m.upDown("system.filesystem.usage", "By", fs.Free, append(attrs, "system.filesystem.state", "free")...)
+		m.upDown("system.filesystem.limit", "By", fs.Total, attrs...)
+		if fs.Total > 0 {
+			m.gauge("system.filesystem.utilization", "1", float64(fs.Used)/float64(fs.Total), attrs...)
+		}
+	}
+
+	for _, intf := range snap.Interfaces {
+		m.counter("system.network.io", "By", intf.Rx, "network.interface.name", intf.Name, "network.io.direction", "receive")
+		m.counter("system.network.io", "By", intf.Tx, "network.interface.name", intf.Name, "network.io.direction", "transmit")
+	}
+
+	// There are no conventions for cgroups outside of containers
+	var cgroups func([]CgroupSnapshot)
+	cgroups = func(list []CgroupSnapshot) {
+		for _, cg := range list {
+			m.counterDouble("rtop.cgroup.cpu.time", "s", cg.CPUUsage, "rtop.cgroup.path", cg.Path)
+			m.upDown("rtop.cgroup.memory.usage", "By", uint64(cg.Memory), "rtop.cgroup.path", cg.Path)
+			if cg.MemoryLimit > 0 {
+				m.upDown("rtop.cgroup.memory.limit", "By", uint64(cg.MemoryLimit), "rtop.cgroup.path", cg.Path)
+			}
+			m.counter("rtop.cgroup.disk.io", "By", uint64(cg.IoRead), "rtop.cgroup.path", cg.Path, "disk.io.direction", "read")
+			m.counter("rtop.cgroup.disk.io", "By", uint64(cg.IoWrite), "rtop.cgroup.path", cg.Path, "disk.io.direction", "write")
+			cgroups(cg.Children)
+		}
+	}
+	cgroups(snap.Cgroups)
+
+	hostname := snap.Hostname
+	if len(hostname) == 0 {
+		hostname = snap.Host
+	}
+	return otlpExport{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: map[string][]otlpAttribute{"attributes": {
+			otlpString("host.name", hostname),
+			otlpString("rtop.target", snap.Host),
+		}},
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope:   map[string]string{"name": "github.com/0x0BSoD/rtop"},
+			Metrics: m.list,
+		}},
+	}}}
+}
@@ -0,0 +1,95 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// otlpServer collects the requests exported to it
+func otlpServer(t *testing.T) (*httptest.Server, *[]otlpExport) {
+	var exports []otlpExport
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var export otlpExport
+		if err := json.Unmarshal(body, &export); err != nil {
+			t.Errorf("bad export: %v: %s", err, body)
+		}
+		exports = append(exports, export)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &exports
+}
+
+func otlpPoints(export otlpExport, name string) []otlpPoint {
+	for _, m := range export.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		if m.Name != name {
+			continue
+		}
+		if m.Sum != nil {
+			return m.Sum.DataPoints
+		}
+		return m.Gauge.DataPoints
+	}
+	return nil
+}
+
+func TestOTLPStartTime(t *testing.T) {
+	srv, exports := otlpServer(t)
+	sink, err := OpenOTLP(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	at := time.Unix(1700000000, 0)
+	for _, round := range []struct {
+		after  time.Duration
+		uptime float64
+	}{
+		{0, 1000},
+		{10 * time.Second, 1010.02}, // read a little late
+		{20 * time.Second, 1019.97},
+		{30 * time.Second, 0},  // uptime unavailable
+		{40 * time.Second, 5},  // rebooted
+		{50 * time.Second, 15}, // since the reboot
+	} {
+		snap := Snapshot{Host: "web1", Timestamp: at.Add(round.after), Uptime: round.uptime,
+			Interfaces: []InterfaceSnapshot{{Name: "eth0"}}}
+		if err := sink.Write(snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	boot := strconv.FormatInt(at.Add(-1000*time.Second).UnixNano(), 10)
+	reboot := strconv.FormatInt(at.Add(35*time.Second).UnixNano(), 10)
+	want := []string{boot, boot, boot, boot, reboot, reboot}
+	if len(*exports) != len(want) {
+		t.Fatalf("%d exports, want %d", len(*exports), len(want))
+	}
+	for i, export := range *exports {
+		for _, p := range otlpPoints(export, "system.network.io") {
+			if p.StartTimeUnixNano != want[i] {
+				t.Errorf("export %d starts at %s, want %s", i, p.StartTimeUnixNano, want[i])
+			}
+		}
+	}
+}
+
+func TestOTLPMemoryStates(t *testing.T) {
+	mem := MemorySnapshot{Total: 4000, Used: 1000, Free: 1000, Buffers: 500, Cached: 1500}
+	export := otlpRequest(Snapshot{Host: "web1", Memory: mem}, time.Now())
+
+	var sum uint64
+	for _, p := range otlpPoints(export, "system.memory.usage") {
+		v, _ := strconv.ParseUint(p.AsInt, 10, 64)
+		sum += v
+	}
+	if sum != mem.Total {
+		t.Errorf("memory states add up to %d, want the limit %d", sum, mem.Total)
+	}
+}
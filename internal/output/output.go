@@ -0,0 +1,20 @@
+// Package output renders stats snapshots for batch mode, where rtop prints to
+// stdout instead of running the interactive display.
+package output
+
+import (
+	"io"
+	"time"
+
+	"github.com/0x0BSoD/rtop/internal/stats"
+)
+
+// Writer writes the last stats collected by a fetcher, as they were at the
+// given time
+type Writer func(w io.Writer, f *stats.SshFetcher, at time.Time) error
+
+// Formats are the writers selectable with --output
+var Formats = map[string]Writer{
+	"text": Text,
+	"json": JSON,
+}
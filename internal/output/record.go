@@ -0,0 +1,226 @@
+package output
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/0x0BSoD/rtop/internal/stats"
+)
+
+// Recording appends snapshots to a session file for rtop replay. A session
+// is a gzip member with a JSON line per snapshot, flushed after each one, so
+// that the file stays readable up to the last snapshot if rtop dies and
+// further sessions can be appended to it. Snapshots compress several times
+// better sharing a member than in one each.
+type Recording struct {
+	mu   sync.Mutex
+	file *os.File
+	zw   *gzip.Writer
+}
+
+func OpenRecording(path string) (*Recording, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	zw, err := gzip.NewWriterLevel(file, gzip.BestCompression)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &Recording{file: file, zw: zw}, nil
+}
+
+func (r *Recording) Write(snap Snapshot) error {
+	line, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.zw.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return r.zw.Flush()
+}
+
+func (r *Recording) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.zw.Close()
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// gzipHeader starts every gzip member, as written by Recording
+var gzipHeader = []byte{0x1f, 0x8b, 8}
+
+// ReadRecording returns the snapshots of a session file in the order they were
+// written. Sessions cut short are read up to their last complete snapshot.
+func ReadRecording(path string) ([]Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	var snaps []Snapshot
+	for first := true; len(data) > 0; first = false {
+		r := bytes.NewReader(data)
+		err := readSession(r, &snaps)
+		var newer newerSchemaError
+		if errors.As(err, &newer) {
+			return snaps, fmt.Errorf("%s was recorded with a newer rtop (schema version %d)", path, newer)
+		}
+		if err == nil {
+			data = data[len(data)-r.Len():]
+			continue
+		}
+		if first && len(snaps) == 0 {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		// A session left without its end by a crash, the next one starts
+		// at the next header
+		next := bytes.Index(data[1:], gzipHeader)
+		if next == -1 {
+			break
+		}
+		data = data[next+1:]
+	}
+	return snaps, nil
+}
+
+type newerSchemaError int
+
+func (e newerSchemaError) Error() string {
+	return fmt.Sprintf("schema version %d", int(e))
+}
+
+// readSession appends the snapshots of the gzip member at the start of r,
+// leaving r after it. The bytes.Reader keeps gzip from reading ahead.
+func readSession(r *bytes.Reader, snaps *[]Snapshot) error {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	zr.Multistream(false)
+	dec := json.NewDecoder(zr)
+	for {
+		var snap Snapshot
+		err := dec.Decode(&snap)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if snap.Version > SchemaVersion {
+			return newerSchemaError(snap.Version)
+		}
+		*snaps = append(*snaps, snap)
+	}
+}
+
+// recordedError stands in for a collector error read back from a recording
+type recordedError struct {
+	reason      string
+	unsupported bool
+}
+
+func (e *recordedError) Error() string {
+	return e.reason
+}
+
+func (e *recordedError) Is(target error) bool {
+	return e.unsupported && target == stats.ErrUnsupported
+}
+
+// Stats turns a snapshot back into the stats it was made from, as far as it
+// keeps them
+func (snap Snapshot) Stats() *stats.Stats {
+	st := &stats.Stats{
+		Uptime:       time.Duration(snap.Uptime * float64(time.Second)),
+		Hostname:     snap.Hostname,
+		Load1:        strconv.FormatFloat(snap.Load[0], 'f', 2, 64),
+		Load5:        strconv.FormatFloat(snap.Load[1], 'f', 2, 64),
+		Load10:       strconv.FormatFloat(snap.Load[2], 'f', 2, 64),
+		RunningProcs: strconv.Itoa(snap.Processes.Running),
+		TotalProcs:   strconv.Itoa(snap.Processes.Total),
+		MemTotal:     snap.Memory.Total,
+		MemFree:      snap.Memory.Free,
+		MemBuffers:   snap.Memory.Buffers,
+		MemCached:    snap.Memory.Cached,
+		SwapTotal:    snap.Memory.SwapTotal,
+		SwapFree:     snap.Memory.SwapFree,
+		NetIntf:      make(map[string]stats.NetIntfInfo, len(snap.Interfaces)),
+		CPU: stats.CPUInfo{
+			User:    float32(snap.CPU.User),
+			Nice:    float32(snap.CPU.Nice),
+			System:  float32(snap.CPU.System),
+			Idle:    float32(snap.CPU.Idle),
+			Iowait:  float32(snap.CPU.Iowait),
+			Irq:     float32(snap.CPU.Irq),
+			SoftIrq: float32(snap.CPU.SoftIrq),
+			Steal:   float32(snap.CPU.Steal),
+			Guest:   float32(snap.CPU.Guest),
+		},
+		Cores:   snap.CPU.Cores,
+		Cgroups: statsCgroups(snap.Cgroups, nil),
+		Errors:  make(map[string]*stats.CollectorError, len(snap.Errors)),
+	}
+	for _, fs := range snap.Filesystems {
+		st.FSInfos = append(st.FSInfos, stats.FSInfo{
+			Device:     fs.Device,
+			MountPoint: fs.MountPoint,
+			Used:       fs.Used,
+			Free:       fs.Free,
+		})
+	}
+	for _, intf := range snap.Interfaces {
+		st.NetIntf[intf.Name] = stats.NetIntfInfo{
+			IPv4:   intf.IPv4,
+			IPv6:   intf.IPv6,
+			Rx:     intf.Rx,
+			Tx:     intf.Tx,
+			RxRate: intf.RxRate,
+			TxRate: intf.TxRate,
+		}
+	}
+	for name, fail := range snap.Errors {
+		st.Errors[name] = &stats.CollectorError{
+			Collector: name,
+			Err:       &recordedError{reason: fail.Reason, unsupported: fail.Unsupported},
+		}
+	}
+	return st
+}
+
+func statsCgroups(snaps []CgroupSnapshot, parent *stats.Cgroup) []*stats.Cgroup {
+	cgroups := make([]*stats.Cgroup, 0, len(snaps))
+	for _, snap := range snaps {
+		cg := &stats.Cgroup{
+			Version:            "v2",
+			Path:               snap.Path,
+			CpuUsage:           snap.CPUUsage,
+			MemoryUsageCurrent: snap.Memory,
+			MemoryUsageLimit:   snap.MemoryLimit,
+			IoReadBytes:        snap.IoRead,
+			IoWriteBytes:       snap.IoWrite,
+			OomKills:           snap.OomKills,
+			Parent:             parent,
+		}
+		cg.Childs = statsCgroups(snap.Children, cg)
+		cgroups = append(cgroups, cg)
+	}
+	return cgroups
+}
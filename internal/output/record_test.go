@@ -0,0 +1,130 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func recordedSnapshot(host string, i int) Snapshot {
+	return Snapshot{
+		Version:   SchemaVersion,
+		Host:      host,
+		Timestamp: time.Unix(1700000000+int64(i), 0).UTC(),
+		Hostname:  host + ".example.com",
+		Uptime:    float64(1000 + i),
+		Load:      [3]float64{0.5, 0.25, 0.125},
+		CPU:       CPUSnapshot{Cores: 2, User: 10, Idle: 90},
+		Memory:    MemorySnapshot{Total: 4096, Used: 1024, Free: 3072},
+		Filesystems: []FilesystemSnapshot{
+			{Device: "/dev/sda1", MountPoint: "/", Used: 10, Free: 90, Total: 100},
+		},
+		Interfaces: []InterfaceSnapshot{{Name: "eth0", Rx: uint64(i), Tx: uint64(2 * i)}},
+		Cgroups: []CgroupSnapshot{{Path: "/sys/fs/cgroup/system.slice", Memory: 100,
+			Children: []CgroupSnapshot{{Path: "/sys/fs/cgroup/system.slice/nginx.service", Memory: 50}}}},
+		Errors: map[string]CollectorFail{"cgroups": {Reason: "find not found", Unsupported: true}},
+	}
+}
+
+func record(t *testing.T, path string, snaps []Snapshot, close bool) {
+	t.Helper()
+	r, err := OpenRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, snap := range snaps {
+		if err := r.Write(snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if close {
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		// As if rtop was killed
+		r.file.Close()
+	}
+}
+
+func TestRecordingRoundTrip(t *testing.T) {
+	var want []Snapshot
+	for i := 0; i < 6; i++ {
+		want = append(want, recordedSnapshot([]string{"web1", "db1"}[i%2], i))
+	}
+
+	tests := []struct {
+		name     string
+		sessions [][]Snapshot
+		closed   []bool
+	}{
+		{"one session", [][]Snapshot{want}, []bool{true}},
+		{"appended sessions", [][]Snapshot{want[:2], want[2:5], want[5:]}, []bool{true, true, true}},
+		{"killed sessions", [][]Snapshot{want[:2], want[2:5], want[5:]}, []bool{false, true, false}},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "session.rec")
+		for i, session := range tt.sessions {
+			record(t, path, session, tt.closed[i])
+		}
+		got, err := ReadRecording(path)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: read back\n%+v\nwant\n%+v", tt.name, got, want)
+		}
+	}
+}
+
+func TestRecordingTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.rec")
+	var snaps []Snapshot
+	for i := 0; i < 3; i++ {
+		snaps = append(snaps, recordedSnapshot("web1", i))
+	}
+	record(t, path, snaps[:2], false)
+	complete, _ := os.Stat(path)
+	record(t, path, snaps[2:], true)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Cut into the second session
+	if err := os.WriteFile(path, data[:complete.Size()+20], 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, snaps[:2]) {
+		t.Errorf("read back %d snapshots, want the 2 complete ones", len(got))
+	}
+}
+
+func TestRecordingErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	notGzip := filepath.Join(dir, "notes.txt")
+	os.WriteFile(notGzip, []byte("not a recording\n"), 0644)
+	if _, err := ReadRecording(notGzip); err == nil {
+		t.Errorf("reading %s did not fail", notGzip)
+	}
+
+	newer := filepath.Join(dir, "newer.rec")
+	snap := recordedSnapshot("web1", 0)
+	snap.Version = SchemaVersion + 1
+	record(t, newer, []Snapshot{recordedSnapshot("web1", 1), snap}, true)
+	got, err := ReadRecording(newer)
+	if err == nil || !strings.Contains(err.Error(), "newer rtop") {
+		t.Errorf("reading a newer schema: %v", err)
+	}
+	if len(got) != 1 {
+		t.Errorf("read %d snapshots before the newer one, want 1", len(got))
+	}
+}
@@ -0,0 +1,127 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink receives a snapshot of every host after each round of collection. It
+// must be safe for concurrent use, rounds of different hosts run in parallel.
+type Sink interface {
+	Write(snap Snapshot) error
+	Close() error
+}
+
+// dialTimeout bounds connecting to a TCP or UDP sink
+const dialTimeout = 5 * time.Second
+
+// maxDatagram keeps UDP packets below the usual MTU
+const maxDatagram = 1400
+
+// openTarget opens where a line based sink writes: tcp://host:port,
+// udp://host:port, or a file path, optionally as file://path, appended to
+func openTarget(target string) (io.WriteCloser, error) {
+	if network, addr, ok := strings.Cut(target, "://"); ok {
+		switch network {
+		case "tcp", "udp":
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				return nil, fmt.Errorf("bad address %s: %w", target, err)
+			}
+			return &netWriter{network: network, addr: addr}, nil
+		case "file":
+			target = addr
+		default:
+			return nil, fmt.Errorf("unknown sink target %s, want tcp://, udp:// or a file", target)
+		}
+	}
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", target, err)
+	}
+	return file, nil
+}
+
+// netWriter dials when it is first written to and again after a failed
+// write, so a restarted collector only loses the rounds it missed
+type netWriter struct {
+	network string
+	addr    string
+	conn    net.Conn
+}
+
+func (w *netWriter) Write(p []byte) (int, error) {
+	if w.conn == nil {
+		conn, err := net.DialTimeout(w.network, w.addr, dialTimeout)
+		if err != nil {
+			return 0, err
+		}
+		w.conn = conn
+	}
+
+	var n int
+	var err error
+	if w.network == "udp" {
+		n, err = w.writeDatagrams(p)
+	} else {
+		n, err = w.conn.Write(p)
+	}
+	if err != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+	return n, err
+}
+
+// writeDatagrams splits p at line ends into packets of at most maxDatagram
+func (w *netWriter) writeDatagrams(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		size := len(p)
+		if size > maxDatagram {
+			size = maxDatagram
+			if i := strings.LastIndexByte(string(p[:size]), '\n'); i >= 0 {
+				size = i + 1
+			}
+		}
+		n, err := w.conn.Write(p[:size])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[size:]
+	}
+	return written, nil
+}
+
+func (w *netWriter) Close() error {
+	if w.conn == nil {
+		return nil
+	}
+	return w.conn.Close()
+}
+
+// lineSink writes each snapshot as lines of text in one write
+type lineSink struct {
+	mu     sync.Mutex
+	w      io.WriteCloser
+	format func(sb *strings.Builder, snap Snapshot)
+}
+
+func (s *lineSink) Write(snap Snapshot) error {
+	var sb strings.Builder
+	s.format(&sb, snap)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := io.WriteString(s.w, sb.String())
+	return err
+}
+
+func (s *lineSink) Close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,132 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/0x0BSoD/rtop/internal/stats"
+)
+
+func formatBytes(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	div, exp := uint64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.2f %cb", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+func formatUptime(d time.Duration) string {
+	days := d / (24 * time.Hour)
+	d %= 24 * time.Hour
+	if days > 0 {
+		return fmt.Sprintf("%dd %02d:%02d:%02d", days, d/time.Hour, (d%time.Hour)/time.Minute, (d%time.Minute)/time.Second)
+	}
+	return fmt.Sprintf("%02d:%02d:%02d", d/time.Hour, (d%time.Hour)/time.Minute, (d%time.Minute)/time.Second)
+}
+
+// Text writes a plain text snapshot of the last stats collected by f, for
+// batch mode
+func Text(w io.Writer, f *stats.SshFetcher, at time.Time) error {
+	var sb strings.Builder
+	st := f.Stats
+
+	sb.WriteString(f.Name)
+	if len(st.Hostname) > 0 && st.Hostname != f.Name {
+		fmt.Fprintf(&sb, " (%s)", st.Hostname)
+	}
+	fmt.Fprintf(&sb, " %s", at.Format("2006-01-02 15:04:05"))
+	if st.Uptime > 0 {
+		fmt.Fprintf(&sb, " up %s", formatUptime(st.Uptime))
+	}
+	sb.WriteString("\n")
+
+	status := f.Status()
+	if status.Reconnecting {
+		fmt.Fprintf(&sb, "RECONNECTING (attempt %d): %v\n", status.Attempts, status.LastError)
+		if !status.LastUpdate.IsZero() {
+			fmt.Fprintf(&sb, "showing data from %s\n", status.LastUpdate.Format("15:04:05"))
+		}
+	}
+	if status.LastUpdate.IsZero() && st.Uptime == 0 {
+		sb.WriteString("no data collected yet\n\n")
+		_, err := io.WriteString(w, sb.String())
+		return err
+	}
+
+	fmt.Fprintf(&sb, "\nLoad:\n    %s %s %s\n", st.Load1, st.Load5, st.Load10)
+	fmt.Fprintf(&sb, "\nProcesses:\n    %s running of %s total\n", st.RunningProcs, st.TotalProcs)
+
+	fmt.Fprintf(&sb, "\nCPU (%d cores):\n", st.Cores)
+	fmt.Fprintf(&sb, "    %.2f%% user, %.2f%% nice, %.2f%% sys, %.2f%% idle, %.2f%% iowait\n",
+		st.CPU.User, st.CPU.Nice, st.CPU.System, st.CPU.Idle, st.CPU.Iowait)
+	fmt.Fprintf(&sb, "    %.2f%% irq, %.2f%% softirq, %.2f%% steal, %.2f%% guest\n",
+		st.CPU.Irq, st.CPU.SoftIrq, st.CPU.Steal, st.CPU.Guest)
+
+	sb.WriteString("\nMemory:\n")
+	fmt.Fprintf(&sb, "    free    = %s\n", formatBytes(st.MemFree))
+	fmt.Fprintf(&sb, "    used    = %s\n", formatBytes(st.MemUsed()))
+	fmt.Fprintf(&sb, "    buffers = %s\n", formatBytes(st.MemBuffers))
+	fmt.Fprintf(&sb, "    cached  = %s\n", formatBytes(st.MemCached))
+	fmt.Fprintf(&sb, "    swap    = %s free of %s\n", formatBytes(st.SwapFree), formatBytes(st.SwapTotal))
+
+	if len(st.FSInfos) > 0 {
+		sb.WriteString("\nFilesystems:\n")
+		width := 0
+		for _, fs := range st.FSInfos {
+			width = max(width, len(fs.MountPoint))
+		}
+		for _, fs := range st.FSInfos {
+			fmt.Fprintf(&sb, "    %-*s  %s free of %s\n", width, fs.MountPoint,
+				formatBytes(fs.Free), formatBytes(fs.Used+fs.Free))
+		}
+	}
+
+	if len(st.NetIntf) > 0 {
+		sb.WriteString("\nNetwork Interfaces:\n")
+		names := make([]string, 0, len(st.NetIntf))
+		width := 0
+		for name := range st.NetIntf {
+			names = append(names, name)
+			width = max(width, len(name))
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			intf := st.NetIntf[name]
+			fmt.Fprintf(&sb, "    %-*s  rx = %s (%s/s), tx = %s (%s/s)",
+				width, name, formatBytes(intf.Rx), formatBytes(uint64(intf.RxRate)),
+				formatBytes(intf.Tx), formatBytes(uint64(intf.TxRate)))
+			for _, addr := range []string{intf.IPv4, intf.IPv6} {
+				if len(addr) > 0 {
+					fmt.Fprintf(&sb, ", %s", addr)
+				}
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	var failing []string
+	for name, err := range st.Errors {
+		if !errors.Is(err, stats.ErrUnsupported) {
+			failing = append(failing, fmt.Sprintf("    %s: %s\n", name, err.Reason()))
+		}
+	}
+	if len(failing) > 0 {
+		sort.Strings(failing)
+		sb.WriteString("\nUnavailable:\n" + strings.Join(failing, ""))
+	}
+	sb.WriteString("\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
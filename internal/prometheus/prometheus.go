@@ -0,0 +1,274 @@
+// Package prometheus exposes the stats of remote hosts in the Prometheus text
+// exposition format, for hosts that can be reached over SSH but where no
+// exporter can be installed.
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0x0BSoD/rtop/internal/output"
+	"github.com/0x0BSoD/rtop/internal/stats"
+	"github.com/0x0BSoD/rtop/pkg/logger"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// hostState is what was collected from a host in its last round
+type hostState struct {
+	snap     output.Snapshot
+	duration time.Duration
+	rounds   int
+}
+
+// Exporter collects from every host each interval and serves the last round
+// on /metrics, so scrapes never wait on SSH
+type Exporter struct {
+	UpdateInterval time.Duration
+
+	fetchers []*stats.SshFetcher
+	mu       sync.Mutex
+	hosts    []hostState
+}
+
+func New(fetchers []*stats.SshFetcher, interval time.Duration) *Exporter {
+	return &Exporter{
+		UpdateInterval: interval,
+		fetchers:       fetchers,
+		hosts:          make([]hostState, len(fetchers)),
+	}
+}
+
+// Run collects from every host until ctx is done
+func (e *Exporter) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i, f := range e.fetchers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.poll(ctx, i, f)
+		}()
+	}
+	wg.Wait()
+}
+
+func (e *Exporter) poll(ctx context.Context, i int, f *stats.SshFetcher) {
+	ticker := time.NewTicker(e.UpdateInterval)
+	defer ticker.Stop()
+	for {
+		start := time.Now()
+		f.GetAllStats(ctx)
+		duration := time.Since(start)
+		snap := output.NewSnapshot(f, time.Now())
+
+		e.mu.Lock()
+		e.hosts[i] = hostState{snap: snap, duration: duration, rounds: e.hosts[i].rounds + 1}
+		e.mu.Unlock()
+		logger.Debug("%s: collected in %v", f.Name, duration)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/metrics":
+		w.Header().Set("Content-Type", contentType)
+		e.WriteMetrics(w)
+	case "/":
+		fmt.Fprintf(w, "<html><head><title>rtop</title></head><body><h1>rtop</h1><p><a href=\"/metrics\">Metrics</a></p></body></html>\n")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+type sample struct {
+	labels []string // name, value pairs
+	value  float64
+}
+
+type family struct {
+	name    string
+	help    string
+	kind    string // gauge or counter
+	samples []sample
+}
+
+// registry keeps the families in the order they were first added
+type registry struct {
+	families []*family
+	byName   map[string]*family
+}
+
+func (r *registry) add(name, kind, help string, value float64, labels ...string) {
+	if r.byName == nil {
+		r.byName = make(map[string]*family)
+	}
+	f, ok := r.byName[name]
+	if !ok {
+		f = &family{name: name, help: help, kind: kind}
+		r.byName[name] = f
+		r.families = append(r.families, f)
+	}
+	f.samples = append(f.samples, sample{labels: labels, value: value})
+}
+
+func (r *registry) gauge(name, help string, value float64, labels ...string) {
+	r.add(name, "gauge", help, value, labels...)
+}
+
+func (r *registry) counter(name, help string, value float64, labels ...string) {
+	r.add(name, "counter", help, value, labels...)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (r *registry) write(w io.Writer) error {
+	var sb strings.Builder
+	for _, f := range r.families {
+		fmt.Fprintf(&sb, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+		for _, s := range f.samples {
+			sb.WriteString(f.name)
+			if len(s.labels) > 0 {
+				sb.WriteString("{")
+				for i := 0; i+1 < len(s.labels); i += 2 {
+					if i > 0 {
+						sb.WriteString(",")
+					}
+					fmt.Fprintf(&sb, `%s="%s"`, s.labels[i], labelEscaper.Replace(s.labels[i+1]))
+				}
+				sb.WriteString("}")
+			}
+			sb.WriteString(" " + strconv.FormatFloat(s.value, 'g', -1, 64) + "\n")
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// WriteMetrics writes the last round of every host
+func (e *Exporter) WriteMetrics(w io.Writer) error {
+	e.mu.Lock()
+	hosts := make([]hostState, len(e.hosts))
+	copy(hosts, e.hosts)
+	e.mu.Unlock()
+
+	var r registry
+	for i, h := range hosts {
+		host := e.fetchers[i].Name
+		up := 0.0
+		if h.rounds > 0 && !h.snap.Reconnecting {
+			up = 1
+		}
+		r.gauge("rtop_up", "Whether the last collection from the host succeeded.", up, "host", host)
+		if h.rounds == 0 {
+			continue
+		}
+		r.gauge("rtop_collection_duration_seconds", "How long the last collection from the host took.",
+			h.duration.Seconds(), "host", host)
+		if up == 0 {
+			continue
+		}
+		addSnapshot(&r, host, h.snap)
+	}
+	return r.write(w)
+}
+
+func addSnapshot(r *registry, host string, snap output.Snapshot) {
+	collectors := make([]string, 0, len(snap.Errors))
+	for name := range snap.Errors {
+		collectors = append(collectors, name)
+	}
+	sort.Strings(collectors)
+	for _, name := range collectors {
+		unsupported := "false"
+		if snap.Errors[name].Unsupported {
+			unsupported = "true"
+		}
+		r.gauge("rtop_collector_failed", "Collectors that failed in the last round, or are not supported by the host.",
+			1, "host", host, "collector", name, "unsupported", unsupported)
+	}
+
+	r.gauge("rtop_uptime_seconds", "Time since the host booted.", snap.Uptime, "host", host)
+	for i, period := range []string{"1", "5", "15"} {
+		r.gauge("rtop_load"+period, "Load average over "+period+" minutes.", snap.Load[i], "host", host)
+	}
+	r.gauge("rtop_procs_running", "Processes in the run queue.", float64(snap.Processes.Running), "host", host)
+	r.gauge("rtop_procs_total", "Processes on the host.", float64(snap.Processes.Total), "host", host)
+
+	r.gauge("rtop_cpu_cores", "Number of CPU cores.", float64(snap.CPU.Cores), "host", host)
+	for _, mode := range []struct {
+		name  string
+		value float64
+	}{
+		{"user", snap.CPU.User},
+		{"nice", snap.CPU.Nice},
+		{"system", snap.CPU.System},
+		{"idle", snap.CPU.Idle},
+		{"iowait", snap.CPU.Iowait},
+		{"irq", snap.CPU.Irq},
+		{"softirq", snap.CPU.SoftIrq},
+		{"steal", snap.CPU.Steal},
+		{"guest", snap.CPU.Guest},
+	} {
+		r.gauge("rtop_cpu_percent", "Share of CPU time per mode over the last interval.",
+			mode.value, "host", host, "mode", mode.name)
+	}
+
+	for _, mem := range []struct {
+		kind  string
+		value uint64
+	}{
+		{"total", snap.Memory.Total},
+		{"used", snap.Memory.Used},
+		{"free", snap.Memory.Free},
+		{"buffers", snap.Memory.Buffers},
+		{"cached", snap.Memory.Cached},
+	} {
+		r.gauge("rtop_memory_bytes", "Memory by kind.", float64(mem.value), "host", host, "kind", mem.kind)
+	}
+	r.gauge("rtop_swap_bytes", "Swap by kind.", float64(snap.Memory.SwapTotal), "host", host, "kind", "total")
+	r.gauge("rtop_swap_bytes", "Swap by kind.", float64(snap.Memory.SwapFree), "host", host, "kind", "free")
+
+	for _, fs := range snap.Filesystems {
+		labels := []string{"host", host, "device", fs.Device, "mountpoint", fs.MountPoint}
+		r.gauge("rtop_filesystem_size_bytes", "Filesystem size.", float64(fs.Total), labels...)
+		r.gauge("rtop_filesystem_used_bytes", "Filesystem space used.", float64(fs.Used), labels...)
+		r.gauge("rtop_filesystem_free_bytes", "Filesystem space free.", float64(fs.Free), labels...)
+	}
+
+	for _, intf := range snap.Interfaces {
+		labels := []string{"host", host, "interface", intf.Name}
+		r.counter("rtop_network_receive_bytes_total", "Bytes received by the interface.", float64(intf.Rx), labels...)
+		r.counter("rtop_network_transmit_bytes_total", "Bytes sent by the interface.", float64(intf.Tx), labels...)
+		r.gauge("rtop_network_receive_bytes_per_second", "Receive rate over the last interval.", intf.RxRate, labels...)
+		r.gauge("rtop_network_transmit_bytes_per_second", "Transmit rate over the last interval.", intf.TxRate, labels...)
+	}
+
+	addCgroups(r, host, snap.Cgroups)
+}
+
+func addCgroups(r *registry, host string, cgroups []output.CgroupSnapshot) {
+	for _, cg := range cgroups {
+		labels := []string{"host", host, "cgroup", cg.Path}
+		r.counter("rtop_cgroup_cpu_usage_seconds_total", "CPU time used by the cgroup.", cg.CPUUsage, labels...)
+		r.gauge("rtop_cgroup_memory_bytes", "Memory used by the cgroup.", float64(cg.Memory), labels...)
+		if cg.MemoryLimit > 0 {
+			r.gauge("rtop_cgroup_memory_limit_bytes", "Memory limit of the cgroup.", float64(cg.MemoryLimit), labels...)
+		}
+		r.counter("rtop_cgroup_io_read_bytes_total", "Bytes read by the cgroup.", float64(cg.IoRead), labels...)
+		r.counter("rtop_cgroup_io_write_bytes_total", "Bytes written by the cgroup.", float64(cg.IoWrite), labels...)
+		addCgroups(r, host, cg.Children)
+	}
+}
@@ -0,0 +1,86 @@
+package prometheus
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/0x0BSoD/rtop/internal/output"
+	"github.com/0x0BSoD/rtop/internal/stats"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	var r registry
+	r.gauge("rtop_up", "Whether it is up.", 1, "host", "web1")
+	r.counter("rtop_rx_total", "Bytes received.", 1.5e9, "host", "web1", "interface", "eth0")
+	r.gauge("rtop_up", "Whether it is up.", 0, "host", `a "quoted" \ host`+"\nand more")
+	r.gauge("rtop_hosts", "Hosts.", 2)
+
+	var sb strings.Builder
+	if err := r.write(&sb); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP rtop_up Whether it is up.
+# TYPE rtop_up gauge
+rtop_up{host="web1"} 1
+rtop_up{host="a \"quoted\" \\ host\nand more"} 0
+# HELP rtop_rx_total Bytes received.
+# TYPE rtop_rx_total counter
+rtop_rx_total{host="web1",interface="eth0"} 1.5e+09
+# HELP rtop_hosts Hosts.
+# TYPE rtop_hosts gauge
+rtop_hosts 2
+`
+	if got := sb.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	names := []string{"web1", "db1", "new1"}
+	var fetchers []*stats.SshFetcher
+	for _, name := range names {
+		f := stats.NewSshFetcher(nil)
+		f.Name = name
+		fetchers = append(fetchers, f)
+	}
+	e := New(fetchers, 0)
+	e.hosts[0] = hostState{rounds: 3, snap: output.Snapshot{
+		Filesystems: []output.FilesystemSnapshot{{Device: "/dev/sda1", MountPoint: "/", Total: 100}},
+		Cgroups: []output.CgroupSnapshot{{Path: "/sys/fs/cgroup/system.slice", Children: []output.CgroupSnapshot{
+			{Path: "/sys/fs/cgroup/system.slice/nginx.service", MemoryLimit: 1024},
+		}}},
+		Errors: map[string]output.CollectorFail{"interface-info": {Unsupported: true}},
+	}}
+	e.hosts[1] = hostState{rounds: 5, snap: output.Snapshot{Reconnecting: true}}
+
+	var sb strings.Builder
+	if err := e.WriteMetrics(&sb); err != nil {
+		t.Fatal(err)
+	}
+	got := sb.String()
+	for _, line := range []string{
+		`rtop_up{host="web1"} 1`,
+		`rtop_up{host="db1"} 0`,
+		`rtop_up{host="new1"} 0`,
+		`rtop_collector_failed{host="web1",collector="interface-info",unsupported="true"} 1`,
+		`rtop_filesystem_size_bytes{host="web1",device="/dev/sda1",mountpoint="/"} 100`,
+		`rtop_cgroup_memory_limit_bytes{host="web1",cgroup="/sys/fs/cgroup/system.slice/nginx.service"} 1024`,
+		`rtop_collection_duration_seconds{host="db1"} 0`,
+	} {
+		if !strings.Contains(got, line+"\n") {
+			t.Errorf("missing %s in\n%s", line, got)
+		}
+	}
+	for _, absent := range []string{
+		`rtop_uptime_seconds{host="db1"}`,
+		`rtop_collection_duration_seconds{host="new1"}`,
+		`rtop_cgroup_memory_limit_bytes{host="web1",cgroup="/sys/fs/cgroup/system.slice"}`,
+	} {
+		if strings.Contains(got, absent) {
+			t.Errorf("unexpected %s in\n%s", absent, got)
+		}
+	}
+	if n := strings.Count(got, "# TYPE rtop_up gauge"); n != 1 {
+		t.Errorf("rtop_up described %d times", n)
+	}
+}
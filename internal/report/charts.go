@@ -0,0 +1,75 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	chartWidth  = 640
+	chartHeight = 160
+)
+
+// palette colours the lines of a chart in turn
+var palette = []string{"#2e86c1", "#e67e22", "#27ae60", "#c0392b", "#8e44ad", "#16a085", "#d4ac0d", "#7f8c8d"}
+
+type series struct {
+	name   string
+	values []float64 // a value per snapshot
+}
+
+// Line is a series laid out on a Chart
+type Line struct {
+	Name   string
+	Color  string
+	Points string // as in the SVG polyline attribute
+}
+
+// Chart is a time series chart, laid out for the template
+type Chart struct {
+	Title  string
+	Width  int
+	Height int
+	Lines  []Line
+	Top    string // the value at the top of the chart
+	Start  string
+	End    string
+}
+
+// newChart lays series out over times, up to top or to the largest value when
+// top is 0
+func newChart(title string, times []time.Time, top float64, format func(float64) string, all ...series) Chart {
+	c := Chart{Title: title, Width: chartWidth, Height: chartHeight}
+	if len(times) == 0 {
+		return c
+	}
+	if top == 0 {
+		for _, s := range all {
+			for _, v := range s.values {
+				top = max(top, v)
+			}
+		}
+		if top == 0 {
+			top = 1
+		}
+	}
+	c.Top = format(top)
+	c.Start = times[0].Format("15:04:05")
+	c.End = times[len(times)-1].Format("15:04:05")
+
+	span := times[len(times)-1].Sub(times[0]).Seconds()
+	for i, s := range all {
+		var points []string
+		for j, v := range s.values {
+			x := 0.0
+			if span > 0 {
+				x = times[j].Sub(times[0]).Seconds() / span * chartWidth
+			}
+			y := chartHeight - min(v/top, 1)*(chartHeight-2) - 1
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+		}
+		c.Lines = append(c.Lines, Line{Name: s.name, Color: palette[i%len(palette)], Points: strings.Join(points, " ")})
+	}
+	return c
+}
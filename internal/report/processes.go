@@ -0,0 +1,111 @@
+package report
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0x0BSoD/rtop/internal/stats"
+)
+
+// maxProcesses is how many of the busiest processes are reported
+const maxProcesses = 15
+
+// processCommand prints the clock tick rate, the page size and the stat line
+// of every process, which may come and go while cat runs
+const processCommand = "getconf CLK_TCK; getconf PAGESIZE; cat /proc/[0-9]*/stat 2>/dev/null; true"
+
+// Process is a process busy during the report
+type Process struct {
+	PID     int
+	Command string
+	CPU     float64 // percent of one core over the report
+	RSS     uint64  // bytes at the end
+}
+
+type procStat struct {
+	comm  string
+	ticks uint64 // user and system time
+	start uint64 // in ticks after boot, tells reused PIDs apart
+	rss   uint64 // pages
+}
+
+type procTable struct {
+	at       time.Time
+	tick     uint64 // per second
+	pageSize uint64
+	procs    map[int]procStat
+}
+
+func readProcesses(ctx context.Context, client stats.Transport) (*procTable, error) {
+	out, err := client.RunCommand(ctx, processCommand)
+	if err != nil {
+		return nil, err
+	}
+	t := &procTable{at: time.Now(), tick: 100, pageSize: 4096, procs: make(map[int]procStat)}
+	lines := strings.Split(out, "\n")
+	if len(lines) > 2 {
+		if v, err := strconv.ParseUint(strings.TrimSpace(lines[0]), 10, 64); err == nil && v > 0 {
+			t.tick = v
+		}
+		if v, err := strconv.ParseUint(strings.TrimSpace(lines[1]), 10, 64); err == nil && v > 0 {
+			t.pageSize = v
+		}
+		lines = lines[2:]
+	}
+	for _, line := range lines {
+		// The command is in parentheses and may hold spaces and parentheses
+		open, end := strings.IndexByte(line, '('), strings.LastIndexByte(line, ')')
+		if open < 0 || end < open {
+			continue
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(line[:open]))
+		if err != nil {
+			continue
+		}
+		// From the state on, fields 3 onwards of proc(5)
+		fields := strings.Fields(line[end+1:])
+		if len(fields) < 22 {
+			continue
+		}
+		utime, _ := strconv.ParseUint(fields[11], 10, 64)
+		stime, _ := strconv.ParseUint(fields[12], 10, 64)
+		start, _ := strconv.ParseUint(fields[19], 10, 64)
+		rss, _ := strconv.ParseUint(fields[21], 10, 64)
+		t.procs[pid] = procStat{comm: line[open+1 : end], ticks: utime + stime, start: start, rss: rss}
+	}
+	return t, nil
+}
+
+// busiest ranks the processes by the CPU time they used between two tables
+func busiest(before, after *procTable) []Process {
+	elapsed := after.at.Sub(before.at).Seconds()
+	if elapsed <= 0 {
+		return nil
+	}
+	var procs []Process
+	for pid, p := range after.procs {
+		ticks := p.ticks
+		if prev, ok := before.procs[pid]; ok && prev.start == p.start {
+			ticks -= prev.ticks
+		}
+		procs = append(procs, Process{
+			PID:     pid,
+			Command: p.comm,
+			CPU:     float64(ticks) / float64(after.tick) / elapsed * 100,
+			RSS:     p.rss * after.pageSize,
+		})
+	}
+	sort.Slice(procs, func(i, j int) bool {
+		if procs[i].CPU != procs[j].CPU {
+			return procs[i].CPU > procs[j].CPU
+		}
+		return procs[i].RSS > procs[j].RSS
+	})
+	if len(procs) > maxProcesses {
+		procs = procs[:maxProcesses]
+	}
+	return procs
+}
@@ -0,0 +1,106 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cannedTransport answers every command with out
+type cannedTransport struct {
+	out string
+}
+
+func (t cannedTransport) RunCommand(ctx context.Context, command string) (string, error) {
+	return t.out, nil
+}
+
+func (t cannedTransport) RunCommandInput(ctx context.Context, command string, stdin io.Reader) (string, error) {
+	return t.out, nil
+}
+
+func (t cannedTransport) Close() error {
+	return nil
+}
+
+// statLine makes a /proc/<pid>/stat line
+func statLine(pid int, comm string, utime, stime, start, rss uint64) string {
+	return fmt.Sprintf("%d (%s) S 1 %d %d 0 -1 4194560 100 0 0 0 %d %d 0 0 20 0 1 0 %d 12345678 %d 18446744073709551615",
+		pid, comm, pid, pid, utime, stime, start, rss)
+}
+
+func TestReadProcesses(t *testing.T) {
+	out := strings.Join([]string{
+		"250",
+		"16384",
+		statLine(1, "systemd", 10, 5, 1, 100),
+		statLine(42, "tmux: server (1)", 7, 3, 99, 2),
+		statLine(43, ") odd (", 1, 1, 100, 3),
+		statLine(44, "a b", 0, 0, 101, 4),
+		"45 (gone",
+		"garbage",
+		"",
+	}, "\n")
+	table, err := readProcesses(context.Background(), cannedTransport{out: out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.tick != 250 || table.pageSize != 16384 {
+		t.Errorf("tick %d, page size %d, want 250 and 16384", table.tick, table.pageSize)
+	}
+	want := map[int]procStat{
+		1:  {comm: "systemd", ticks: 15, start: 1, rss: 100},
+		42: {comm: "tmux: server (1)", ticks: 10, start: 99, rss: 2},
+		43: {comm: ") odd (", ticks: 2, start: 100, rss: 3},
+		44: {comm: "a b", ticks: 0, start: 101, rss: 4},
+	}
+	if !reflect.DeepEqual(table.procs, want) {
+		t.Errorf("read\n%+v\nwant\n%+v", table.procs, want)
+	}
+
+	// getconf missing
+	table, err = readProcesses(context.Background(), cannedTransport{out: "\n\n" + statLine(1, "init", 1, 1, 1, 1) + "\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.tick != 100 || table.pageSize != 4096 || len(table.procs) != 1 {
+		t.Errorf("without getconf: %+v", table)
+	}
+}
+
+func TestBusiest(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	before := &procTable{at: at, tick: 100, pageSize: 4096, procs: map[int]procStat{
+		1: {comm: "idle", ticks: 500, start: 1, rss: 10},
+		2: {comm: "busy", ticks: 100, start: 2, rss: 10},
+		3: {comm: "old", ticks: 900, start: 3, rss: 10},
+	}}
+	after := &procTable{at: at.Add(10 * time.Second), tick: 100, pageSize: 4096, procs: map[int]procStat{
+		1: {comm: "idle", ticks: 500, start: 1, rss: 20},
+		2: {comm: "busy", ticks: 600, start: 2, rss: 10},
+		3: {comm: "new", ticks: 200, start: 50, rss: 10}, // the PID was reused
+	}}
+	want := []Process{
+		{PID: 2, Command: "busy", CPU: 50, RSS: 10 * 4096},
+		{PID: 3, Command: "new", CPU: 20, RSS: 10 * 4096},
+		{PID: 1, Command: "idle", CPU: 0, RSS: 20 * 4096},
+	}
+	if got := busiest(before, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%+v\nwant\n%+v", got, want)
+	}
+
+	many := &procTable{at: after.at, tick: 100, pageSize: 4096, procs: make(map[int]procStat)}
+	for pid := 1; pid <= maxProcesses+5; pid++ {
+		many.procs[pid] = procStat{comm: "p", ticks: uint64(pid)}
+	}
+	if got := busiest(&procTable{at: at}, many); len(got) != maxProcesses || got[0].PID != maxProcesses+5 {
+		t.Errorf("kept %d processes, busiest %+v", len(got), got[0])
+	}
+	if got := busiest(after, before); got != nil {
+		t.Errorf("tables out of order ranked %+v", got)
+	}
+}
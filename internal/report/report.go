@@ -0,0 +1,349 @@
+// Package report samples a host for a while and writes what it saw as a
+// single static HTML page, to attach to incident postmortems.
+package report
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/0x0BSoD/rtop/internal/alert"
+	"github.com/0x0BSoD/rtop/internal/output"
+	"github.com/0x0BSoD/rtop/internal/stats"
+	"github.com/0x0BSoD/rtop/pkg/logger"
+)
+
+//go:embed report.html
+var page string
+
+// maxCgroups is how many of the busiest cgroups are reported
+const maxCgroups = 10
+
+// Report is what was collected from a host over a period
+type Report struct {
+	Host       string
+	Start      time.Time
+	End        time.Time
+	Interval   time.Duration
+	Caps       *stats.Capabilities
+	Snapshots  []output.Snapshot // of the rounds the host was reachable in
+	Missed     int               // rounds it was not
+	Processes  []Process         // the busiest over the period
+	ProcessErr string            // why there are none
+	Rules      bool              // alert rules were evaluated
+	Alerts     []alert.Event     // oldest first
+}
+
+// Sample collects from f every interval for duration, or until ctx is done.
+// f must hold a first round of stats already, as a baseline for CPU usage and
+// rates.
+func Sample(ctx context.Context, f *stats.SshFetcher, duration, interval time.Duration) *Report {
+	r := &Report{Host: f.Name, Start: time.Now(), Interval: interval, Caps: f.Caps}
+	before, err := readProcesses(ctx, f.Transport)
+	if err != nil {
+		logger.Warn("%s: failed to read processes: %v", f.Name, err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	deadline := time.NewTimer(duration)
+	defer deadline.Stop()
+sampling:
+	for {
+		select {
+		case <-ctx.Done():
+			break sampling
+		case <-deadline.C:
+			break sampling
+		case <-ticker.C:
+		}
+		// Stopping early must not cut a round short
+		f.GetAllStats(context.Background())
+		if f.Status().Reconnecting {
+			r.Missed++
+			continue
+		}
+		r.Snapshots = append(r.Snapshots, output.NewSnapshot(f, time.Now()))
+	}
+	r.End = time.Now()
+	r.Caps = f.Caps
+
+	if before == nil {
+		r.ProcessErr = "processes could not be read at the start"
+	} else if f.Status().Reconnecting {
+		r.ProcessErr = "the host was unreachable at the end"
+	} else if after, err := readProcesses(context.Background(), f.Transport); err != nil {
+		r.ProcessErr = err.Error()
+	} else {
+		r.Processes = busiest(before, after)
+	}
+	return r
+}
+
+// AddAlerts takes the alerts of the host that fired or resolved during the
+// report from engine
+func (r *Report) AddAlerts(engine *alert.Engine) {
+	r.Rules = true
+	history := engine.History()
+	for i := len(history) - 1; i >= 0; i-- {
+		ev := history[i]
+		if ev.Host == r.Host && !ev.At.Before(r.Start) {
+			r.Alerts = append(r.Alerts, ev)
+		}
+	}
+}
+
+type summaryRow struct {
+	Name                string
+	Min, Avg, Max, Last string
+}
+
+type fsRow struct {
+	output.FilesystemSnapshot
+	Pct    float64
+	Change int64 // bytes used more than at the start
+}
+
+type cgroupRow struct {
+	output.CgroupSnapshot
+	CPU         float64 // percent of one core over the report
+	IoRead      uint64  // during the report
+	IoWrite     uint64
+	NewOomKills int
+}
+
+type failRow struct {
+	Collector string
+	output.CollectorFail
+}
+
+// view is what the template shows
+type view struct {
+	*Report
+	Last        output.Snapshot
+	Charts      []Chart
+	Summary     []summaryRow
+	Filesystems []fsRow
+	Cgroups     []cgroupRow
+	Failed      []failRow
+}
+
+func percent(part, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
+
+// netRates sums up traffic over all interfaces but loopback
+func netRates(snap output.Snapshot) (rx, tx float64) {
+	for _, intf := range snap.Interfaces {
+		if intf.Name != "lo" {
+			rx += intf.RxRate
+			tx += intf.TxRate
+		}
+	}
+	return
+}
+
+func newView(r *Report) view {
+	v := view{Report: r}
+	snaps := r.Snapshots
+	if len(snaps) == 0 {
+		return v
+	}
+	first, last := snaps[0], snaps[len(snaps)-1]
+	v.Last = last
+
+	times := make([]time.Time, len(snaps))
+	metric := func(name string, value func(s output.Snapshot) float64) series {
+		s := series{name: name, values: make([]float64, len(snaps))}
+		for i, snap := range snaps {
+			s.values[i] = value(snap)
+		}
+		return s
+	}
+	for i, snap := range snaps {
+		times[i] = snap.Timestamp
+	}
+
+	cpuUsed := metric("used", func(s output.Snapshot) float64 { return 100 - s.CPU.Idle })
+	iowait := metric("iowait", func(s output.Snapshot) float64 { return s.CPU.Iowait })
+	load1 := metric("1 min", func(s output.Snapshot) float64 { return s.Load[0] })
+	memUsed := metric("used", func(s output.Snapshot) float64 { return float64(s.Memory.Used) })
+	swapUsed := metric("swap", func(s output.Snapshot) float64 { return float64(s.Memory.SwapTotal - s.Memory.SwapFree) })
+	rx := metric("receive", func(s output.Snapshot) float64 { rx, _ := netRates(s); return rx })
+	tx := metric("transmit", func(s output.Snapshot) float64 { _, tx := netRates(s); return tx })
+
+	// Load is drawn against the number of cores, unless it went beyond
+	loadTop := float64(last.CPU.Cores)
+	for _, snap := range snaps {
+		loadTop = max(loadTop, snap.Load[0], snap.Load[1], snap.Load[2])
+	}
+
+	v.Charts = append(v.Charts,
+		newChart("CPU %", times, 100, formatPercent, cpuUsed,
+			metric("user", func(s output.Snapshot) float64 { return s.CPU.User }),
+			metric("system", func(s output.Snapshot) float64 { return s.CPU.System }),
+			iowait,
+			metric("steal", func(s output.Snapshot) float64 { return s.CPU.Steal })),
+		newChart("Load average", times, loadTop, formatFloat, load1,
+			metric("5 min", func(s output.Snapshot) float64 { return s.Load[1] }),
+			metric("15 min", func(s output.Snapshot) float64 { return s.Load[2] })),
+		newChart("Memory", times, float64(last.Memory.Total), formatBytes, memUsed,
+			metric("cached", func(s output.Snapshot) float64 { return float64(s.Memory.Cached) }),
+			metric("buffers", func(s output.Snapshot) float64 { return float64(s.Memory.Buffers) }),
+			swapUsed))
+
+	var disks []series
+	for _, fs := range last.Filesystems {
+		mount := fs.MountPoint
+		disks = append(disks, metric(mount, func(s output.Snapshot) float64 {
+			for _, fs := range s.Filesystems {
+				if fs.MountPoint == mount {
+					return percent(fs.Used, fs.Total)
+				}
+			}
+			return 0
+		}))
+	}
+	v.Charts = append(v.Charts,
+		newChart("Disk space used %", times, 100, formatPercent, disks...),
+		newChart("Network, loopback excepted", times, 0, formatRate, rx, tx))
+
+	summarize := func(name string, s series, format func(float64) string) {
+		lo, hi, sum := s.values[0], s.values[0], 0.0
+		for _, v := range s.values {
+			lo, hi, sum = min(lo, v), max(hi, v), sum+v
+		}
+		v.Summary = append(v.Summary, summaryRow{
+			Name: name, Min: format(lo), Avg: format(sum / float64(len(s.values))), Max: format(hi),
+			Last: format(s.values[len(s.values)-1]),
+		})
+	}
+	summarize("CPU used", cpuUsed, formatPercent)
+	summarize("CPU iowait", iowait, formatPercent)
+	summarize("Load, 1 min", load1, formatFloat)
+	summarize("Memory used", memUsed, formatBytes)
+	summarize("Swap used", swapUsed, formatBytes)
+	summarize("Network receive", rx, formatRate)
+	summarize("Network transmit", tx, formatRate)
+
+	for _, fs := range last.Filesystems {
+		row := fsRow{FilesystemSnapshot: fs, Pct: percent(fs.Used, fs.Total)}
+		for _, old := range first.Filesystems {
+			if old.MountPoint == fs.MountPoint {
+				row.Change = int64(fs.Used) - int64(old.Used)
+			}
+		}
+		v.Filesystems = append(v.Filesystems, row)
+	}
+	sort.Slice(v.Filesystems, func(i, j int) bool { return v.Filesystems[i].Pct > v.Filesystems[j].Pct })
+
+	v.Cgroups = busiestCgroups(first, last)
+
+	for name, fail := range last.Errors {
+		v.Failed = append(v.Failed, failRow{Collector: name, CollectorFail: fail})
+	}
+	sort.Slice(v.Failed, func(i, j int) bool { return v.Failed[i].Collector < v.Failed[j].Collector })
+	return v
+}
+
+func flatten(cgroups []output.CgroupSnapshot, into map[string]output.CgroupSnapshot) {
+	for _, cg := range cgroups {
+		into[cg.Path] = cg
+		flatten(cg.Children, into)
+	}
+}
+
+// busiestCgroups ranks the cgroups by the CPU time they used between two
+// snapshots
+func busiestCgroups(first, last output.Snapshot) []cgroupRow {
+	before := make(map[string]output.CgroupSnapshot)
+	flatten(first.Cgroups, before)
+	after := make(map[string]output.CgroupSnapshot)
+	flatten(last.Cgroups, after)
+
+	elapsed := last.Timestamp.Sub(first.Timestamp).Seconds()
+	var rows []cgroupRow
+	for path, cg := range after {
+		row := cgroupRow{CgroupSnapshot: cg}
+		row.Path = strings.TrimPrefix(path, "/sys/fs/cgroup/")
+		if old, ok := before[path]; ok {
+			if elapsed > 0 {
+				row.CPU = (cg.CPUUsage - old.CPUUsage) / elapsed * 100
+			}
+			row.IoRead = uint64(max(cg.IoRead-old.IoRead, 0))
+			row.IoWrite = uint64(max(cg.IoWrite-old.IoWrite, 0))
+			row.NewOomKills = cg.OomKills - old.OomKills
+		}
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].CPU != rows[j].CPU {
+			return rows[i].CPU > rows[j].CPU
+		}
+		return rows[i].Memory > rows[j].Memory
+	})
+	if len(rows) > maxCgroups {
+		rows = rows[:maxCgroups]
+	}
+	return rows
+}
+
+func formatBytes(v float64) string {
+	const unit = 1024
+	if v < unit {
+		return fmt.Sprintf("%.0f B", v)
+	}
+	exp := 0
+	for v /= unit; v >= unit && exp < 5; v /= unit {
+		exp++
+	}
+	return fmt.Sprintf("%.2f %ciB", v, "KMGTPE"[exp])
+}
+
+func formatRate(v float64) string {
+	return formatBytes(v) + "/s"
+}
+
+func formatPercent(v float64) string {
+	return fmt.Sprintf("%.1f%%", v)
+}
+
+func formatFloat(v float64) string {
+	return fmt.Sprintf("%.2f", v)
+}
+
+var funcs = template.FuncMap{
+	"bytes":   func(v uint64) string { return formatBytes(float64(v)) },
+	"size":    func(v int) string { return formatBytes(float64(v)) },
+	"percent": formatPercent,
+	"change": func(v int64) string {
+		if v < 0 {
+			return "−" + formatBytes(float64(-v))
+		}
+		return "+" + formatBytes(float64(v))
+	},
+	"uptime": func(secs float64) string {
+		return (time.Duration(secs) * time.Second).String()
+	},
+	"clock": func(t time.Time) string { return t.Format("15:04:05") },
+	"date":  func(t time.Time) string { return t.Format("2006-01-02 15:04:05 MST") },
+	"round": func(d time.Duration) string { return d.Round(time.Second).String() },
+	"value": func(v float64) string {
+		return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", v), "0"), ".")
+	},
+}
+
+var tmpl = template.Must(template.New("report").Funcs(funcs).Parse(page))
+
+// Write renders the report as a standalone HTML page
+func Write(w io.Writer, r *Report) error {
+	return tmpl.Execute(w, newView(r))
+}
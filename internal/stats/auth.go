@@ -2,6 +2,7 @@ package stats
 
 import (
 	"bufio"
+	"context"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
@@ -14,6 +15,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/0x0BSoD/rtop/pkg/logger"
@@ -32,100 +34,196 @@ func parsePemBlock(block *pem.Block) (interface{}, error) {
 	}
 }
 
-func addDefaultKeys(auths []ssh.AuthMethod) []ssh.AuthMethod {
+func defaultKeyPaths() []string {
 	homeDir := "~"
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		logger.Warn("Failed to get home directory")
 		// Try to use just ~
 	}
-	defaultKeys := []string{
+	return []string{
 		filepath.Join(homeDir, "/.ssh/id_rsa"),
 		filepath.Join(homeDir, "/.ssh/id_ecdsa"),
 		filepath.Join(homeDir, "/.ssh/id_ed25519"),
 		filepath.Join(homeDir, "/.ssh/id_dsa"),
 	}
+}
 
-	for _, key := range defaultKeys {
+func addDefaultKeys(auths []ssh.AuthMethod) []ssh.AuthMethod {
+	for _, key := range defaultKeyPaths() {
 		if _, err := os.Stat(key); err == nil {
-			auths = addKeyAuth(auths, key)
+			withKey, err := addKeyAuth(auths, key, "")
+			if err != nil {
+				logger.Warn("Skipping key: %v", err)
+				continue
+			}
+			auths = withKey
 		}
 	}
 
 	return auths
 }
 
-// Auth by key
-func addKeyAuth(auths []ssh.AuthMethod, keypath string) []ssh.AuthMethod {
+// loadCertificate reads an OpenSSH certificate such as id_ed25519-cert.pub
+func loadCertificate(certpath string) (*ssh.Certificate, error) {
+	data, err := os.ReadFile(certpath)
+	if err != nil {
+		return nil, err
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", certpath, err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a certificate", certpath)
+	}
+	return cert, nil
+}
+
+// certPaths lists the certificate files worth trying for a key: the
+// explicit CertificateFile first, then the conventional <key>-cert.pub
+func certPaths(keypath, certpath string) []string {
+	var paths []string
+	if len(certpath) > 0 {
+		paths = append(paths, certpath)
+	}
+	if len(keypath) > 0 {
+		paths = append(paths, keypath+"-cert.pub")
+	}
+	return paths
+}
+
+// withCertificates returns the signer preceded by a certificate signer for
+// every certificate in certpaths issued for its key
+func withCertificates(signer ssh.Signer, certpaths []string) []ssh.Signer {
+	var signers []ssh.Signer
+	for _, certpath := range certpaths {
+		if _, err := os.Stat(certpath); err != nil {
+			continue
+		}
+		cert, err := loadCertificate(certpath)
+		if err != nil {
+			logger.Warn("Skipping certificate: %v", err)
+			continue
+		}
+		certSigner, err := ssh.NewCertSigner(cert, signer)
+		if err != nil {
+			logger.Debug("Certificate %s does not match key: %v", certpath, err)
+			continue
+		}
+		logger.Debug("Using certificate %s (key id %q)", certpath, cert.KeyId)
+		signers = append(signers, certSigner)
+	}
+	return append(signers, signer)
+}
+
+// Decrypted keys, so connecting to many hosts asks for a passphrase once
+var (
+	signerMu    sync.Mutex
+	signerCache = make(map[string]ssh.Signer)
+)
+
+// Auth by key, paired with its certificate when one is present
+func addKeyAuth(auths []ssh.AuthMethod, keypath, certpath string) ([]ssh.AuthMethod, error) {
 	if len(keypath) == 0 {
-		return auths
+		return auths, nil
+	}
+
+	signerMu.Lock()
+	defer signerMu.Unlock()
+	if signer, ok := signerCache[keypath]; ok {
+		return append(auths, ssh.PublicKeys(withCertificates(signer, certPaths(keypath, certpath))...)), nil
 	}
 
 	// read the file
 	pemBytes, err := os.ReadFile(keypath)
 	if err != nil {
-		logger.Error("Failed to read pem file %s", keypath)
-		os.Exit(1)
+		return auths, fmt.Errorf("failed to read key: %w", err)
 	}
 
 	// Attempt to parse as an unencrypted private key
 	signer, err := ssh.ParsePrivateKey(pemBytes)
 	if err == nil {
-		return append(auths, ssh.PublicKeys(signer))
+		signerCache[keypath] = signer
+		return append(auths, ssh.PublicKeys(withCertificates(signer, certPaths(keypath, certpath))...)), nil
 	}
 
 	// If parsing fails, assume the key is encrypted and request a passphrase
 	var passphraseMissingError *ssh.PassphraseMissingError
 	if errors.As(err, &passphraseMissingError) {
 		prompt := fmt.Sprintf("Enter passphrase for key '%s': ", keypath)
-		passphrase, err := getpass(prompt)
+		passphrase, err := readSecret(prompt, PassphraseCommand, []string{"RTOP_KEY_FILE=" + keypath})
 		if err != nil {
 			logger.Error("failed to get passphrase: %v", err)
-			return auths
+			return auths, nil
 		}
 
 		// Try parsing the key with the passphrase
 		signer, err = ssh.ParsePrivateKeyWithPassphrase(pemBytes, []byte(passphrase))
 		if err != nil {
 			logger.Error("failed to decrypt private key: %v", err)
-			return auths
+			return auths, nil
 		}
+		signerCache[keypath] = signer
 
-		return append(auths, ssh.PublicKeys(signer))
+		return append(auths, ssh.PublicKeys(withCertificates(signer, certPaths(keypath, certpath))...)), nil
 
 	}
 
 	logger.Warn("invalid private key file: %v", err)
-	return auths
+	return auths, nil
+}
+
+// agentSigners offers the agent's certificates first, then its plain keys
+// paired with any matching certificate file found on disk
+func agentSigners(ag agent.ExtendedAgent, certpaths []string) func() ([]ssh.Signer, error) {
+	return func() ([]ssh.Signer, error) {
+		signers, err := ag.Signers()
+		if err != nil {
+			return nil, err
+		}
+
+		var certs, plain []ssh.Signer
+		for _, signer := range signers {
+			if _, ok := signer.PublicKey().(*ssh.Certificate); ok {
+				certs = append(certs, signer)
+				continue
+			}
+			paired := withCertificates(signer, certpaths)
+			certs = append(certs, paired[:len(paired)-1]...)
+			plain = append(plain, signer)
+		}
+		return append(certs, plain...), nil
+	}
 }
 
-// SSH Agent Auth
-func getAgentAuth() (bool, ssh.AuthMethod) {
+// SSH Agent Auth, over a connection to the agent for the caller to close
+func getAgentAuth(certpaths []string) (net.Conn, ssh.AuthMethod) {
 	if sock := os.Getenv("SSH_AUTH_SOCK"); len(sock) > 0 {
 		if agconn, err := net.Dial("unix", sock); err == nil {
 			ag := agent.NewClient(agconn)
-			return true, ssh.PublicKeysCallback(ag.Signers)
+			return agconn, ssh.PublicKeysCallback(agentSigners(ag, certpaths))
 		}
 	}
-	return false, nil
+	return nil, nil
 }
 
-func tryAgentConnect(user, addr string) (*ssh.Client, error) {
-	ok, auth := getAgentAuth()
-	if !ok {
+func tryAgentConnect(ctx context.Context, user, addr string, certpaths []string) (*ssh.Client, error) {
+	agconn, auth := getAgentAuth(certpaths)
+	if agconn == nil {
 		return nil, nil
 	}
+	// The agent is only needed to sign during the handshake
+	defer agconn.Close()
 
 	config := &ssh.ClientConfig{
-		User: user,
-		Auth: []ssh.AuthMethod{auth},
-		// dummy check
-		HostKeyCallback: func(string, net.Addr, ssh.PublicKey) error {
-			return nil
-		},
+		User:            user,
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: hostKeyCallback(),
 	}
 
-	client, err := ssh.Dial("tcp", addr, config)
+	client, err := dialContext(ctx, addr, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to SSH: %w", err)
 	}
@@ -171,8 +269,15 @@ func getpass(prompt string) (string, error) {
 	return string(passbytes), nil
 }
 
-func addPasswordAuth(user, addr string, auths []ssh.AuthMethod) []ssh.AuthMethod {
-	if terminal.IsTerminal(0) == false {
+// ReadSecret asks for a secret through SSH_ASKPASS or the terminal
+func ReadSecret(prompt string) (string, error) {
+	return readSecret(prompt, "", nil)
+}
+
+// addPasswordAuth asks for the password once and keeps it in remember for
+// later reconnects
+func addPasswordAuth(user, addr string, auths []ssh.AuthMethod, remember *string) []ssh.AuthMethod {
+	if !canReadSecret(PasswordCommand) {
 		return auths
 	}
 	host := addr
@@ -181,7 +286,12 @@ func addPasswordAuth(user, addr string, auths []ssh.AuthMethod) []ssh.AuthMethod
 	}
 	prompt := fmt.Sprintf("%s@%s's password: ", user, host)
 	passwordCallback := func() (string, error) {
-		return getpass(prompt)
+		if len(*remember) > 0 {
+			return *remember, nil
+		}
+		password, err := readSecret(prompt, PasswordCommand, []string{"RTOP_USER=" + user, "RTOP_HOST=" + host})
+		*remember = password
+		return password, err
 	}
 	return append(auths, ssh.PasswordCallback(passwordCallback))
 }
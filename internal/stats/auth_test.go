@@ -0,0 +1,54 @@
+package stats
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestAgentConnectionClosed(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "agent")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	t.Setenv("SSH_AUTH_SOCK", sock)
+
+	served := make(chan struct{})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				agent.ServeAgent(agent.NewKeyring(), conn)
+				served <- struct{}{}
+			}()
+		}
+	}()
+
+	// Nothing listens there, as when redialing a host that is down
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := closed.Addr().String()
+	closed.Close()
+
+	for i := 0; i < 3; i++ {
+		if _, err := tryAgentConnect(context.Background(), "test", addr, nil); err == nil {
+			t.Fatal("connecting to a closed port did not fail")
+		}
+		select {
+		case <-served:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("attempt %d left its agent connection open", i)
+		}
+	}
+}
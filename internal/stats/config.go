@@ -30,15 +30,17 @@ import (
 	"log"
 	"os"
 	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
 
 type Section struct {
-	Hostname     string
-	Port         int
-	User         string
-	IdentityFile string
+	Hostname        string
+	Port            int
+	User            string
+	IdentityFile    string
+	CertificateFile string
 }
 
 func (s *Section) clear() {
@@ -46,9 +48,10 @@ func (s *Section) clear() {
 	s.Port = 0
 	s.User = ""
 	s.IdentityFile = ""
+	s.CertificateFile = ""
 }
 
-func (s *Section) getFull(def Section) (host string, port int, user, keyfile string) {
+func (s *Section) getFull(def Section) (host string, port int, user, keyfile, certfile string) {
 	if len(s.Hostname) > 0 {
 		host = s.Hostname
 	} else if len(def.Hostname) > 0 {
@@ -69,12 +72,17 @@ func (s *Section) getFull(def Section) (host string, port int, user, keyfile str
 	} else if len(def.IdentityFile) > 0 {
 		keyfile = def.IdentityFile
 	}
+	if len(s.CertificateFile) > 0 {
+		certfile = s.CertificateFile
+	} else if len(def.CertificateFile) > 0 {
+		certfile = def.CertificateFile
+	}
 	return
 }
 
 var HostInfo = make(map[string]Section)
 
-func GetSshEntry(name string) (host string, port int, user, keyfile string) {
+func GetSshEntry(name string) (host string, port int, user, keyfile, certfile string) {
 
 	def := Section{Hostname: name}
 	if defcfg, ok := HostInfo["*"]; ok {
@@ -89,7 +97,19 @@ func GetSshEntry(name string) (host string, port int, user, keyfile string) {
 			return s.getFull(def)
 		}
 	}
-	return def.Hostname, def.Port, def.User, def.IdentityFile
+	return def.Hostname, def.Port, def.User, def.IdentityFile, def.CertificateFile
+}
+
+// expandHome resolves a leading ~/ the way ssh_config does for file paths
+func expandHome(p string) string {
+	if !strings.HasPrefix(p, "~/") {
+		return p
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return filepath.Join(homeDir, p[2:])
 }
 
 func ParseSshConfig(path string) bool {
@@ -140,7 +160,11 @@ func ParseSshConfig(path string) bool {
 				})
 			case "identityfile":
 				update(func(s *Section) {
-					s.IdentityFile = parts[1]
+					s.IdentityFile = expandHome(parts[1])
+				})
+			case "certificatefile":
+				update(func(s *Section) {
+					s.CertificateFile = expandHome(parts[1])
 				})
 			}
 		}
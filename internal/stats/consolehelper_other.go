@@ -5,6 +5,7 @@ package stats
 import (
 	"io"
 	"os"
+	"os/exec"
 )
 
 func clearConsole() {}
@@ -12,3 +13,7 @@ func clearConsole() {}
 func getOutput() io.Writer {
 	return os.Stdout
 }
+
+func localShell(command string) *exec.Cmd {
+	return exec.Command("/bin/sh", "-c", command)
+}
@@ -16,3 +16,7 @@ func clearConsole() {
 func getOutput() io.Writer {
 	return colorable.NewColorableStdout()
 }
+
+func localShell(command string) *exec.Cmd {
+	return exec.Command("cmd", "/c", command)
+}
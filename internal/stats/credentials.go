@@ -0,0 +1,103 @@
+package stats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/0x0BSoD/rtop/pkg/logger"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// Shell commands printing a secret on stdout, for headless use. Details about
+// what is being asked for are passed in RTOP_* environment variables, never
+// on the command line.
+var (
+	PasswordCommand   = os.Getenv("RTOP_PASSWORD_COMMAND")
+	PassphraseCommand = os.Getenv("RTOP_PASSPHRASE_COMMAND")
+)
+
+var promptMu sync.Mutex
+
+var errNoSecretSource = errors.New("no terminal, askpass program or secret command available")
+
+// runSecretCommand runs a user-supplied command and returns its first line
+func runSecretCommand(command string, env []string) (string, error) {
+	cmd := localShell(command)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stderr = os.Stderr
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("secret command failed: %w", err)
+	}
+	return firstLine(out.String()), nil
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i != -1 {
+		s = s[:i]
+	}
+	return strings.TrimSuffix(s, "\r")
+}
+
+// askpassProgram returns the SSH_ASKPASS program to use, following the same
+// SSH_ASKPASS_REQUIRE rules as ssh(1)
+func askpassProgram() string {
+	program := os.Getenv("SSH_ASKPASS")
+	if len(program) == 0 {
+		return ""
+	}
+	switch os.Getenv("SSH_ASKPASS_REQUIRE") {
+	case "never":
+		return ""
+	case "prefer", "force":
+		return program
+	}
+	if terminal.IsTerminal(0) || len(os.Getenv("DISPLAY")) == 0 {
+		return ""
+	}
+	return program
+}
+
+func askpass(program, prompt string) (string, error) {
+	cmd := exec.Command(program, prompt)
+	cmd.Stderr = os.Stderr
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s failed: %w", program, err)
+	}
+	return firstLine(out.String()), nil
+}
+
+// readSecret tries the secret command, then SSH_ASKPASS, then the terminal
+func readSecret(prompt, command string, env []string) (string, error) {
+	// Hosts are dialed concurrently, prompts must not interleave
+	promptMu.Lock()
+	defer promptMu.Unlock()
+
+	if len(command) > 0 {
+		logger.Debug("Reading secret from command")
+		return runSecretCommand(command, append(env, "RTOP_PROMPT="+prompt))
+	}
+	if program := askpassProgram(); len(program) > 0 {
+		logger.Debug("Reading secret with %s", program)
+		return askpass(program, prompt)
+	}
+	if terminal.IsTerminal(0) {
+		return getpass(prompt)
+	}
+	return "", errNoSecretSource
+}
+
+// canReadSecret reports whether readSecret has anywhere to read from
+func canReadSecret(command string) bool {
+	return len(command) > 0 || len(askpassProgram()) > 0 || terminal.IsTerminal(0)
+}
@@ -0,0 +1,110 @@
+package stats
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnsupported marks collectors disabled because the host lacks what they need
+var ErrUnsupported = errors.New("not supported on this host")
+
+// CommandError is a remote command that ran but did not succeed
+type CommandError struct {
+	Command string
+	Status  int // exit status, -1 when the command was killed by a signal
+	Stderr  string
+	Err     error
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("failed to run command '%s': %v: %s", e.Command, e.Err, strings.TrimSpace(e.Stderr))
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// commandNotFound reports whether err is the shell failing to find a command
+func commandNotFound(err error) bool {
+	var cmdErr *CommandError
+	return errors.As(err, &cmdErr) && cmdErr.Status == 127
+}
+
+// ConnectionError means the link to the host is gone, as opposed to a
+// command failing on a healthy connection
+type ConnectionError struct {
+	Err error
+}
+
+func (e *ConnectionError) Error() string {
+	return "connection lost: " + e.Err.Error()
+}
+
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+// CollectorError is the failure of a single collector. The fields it fills
+// keep their previous values and the rest of Stats is still valid.
+type CollectorError struct {
+	Collector string
+	Err       error
+}
+
+func (e *CollectorError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Collector, e.Err)
+}
+
+func (e *CollectorError) Unwrap() error {
+	return e.Err
+}
+
+// Reason is a short explanation suitable for display next to a panel
+func (e *CollectorError) Reason() string {
+	var connErr *ConnectionError
+	if errors.As(e.Err, &connErr) {
+		return "connection lost"
+	}
+	var cmdErr *CommandError
+	if errors.As(e.Err, &cmdErr) {
+		if cmdErr.Status == 127 {
+			if name := missingCommand(cmdErr.Command, cmdErr.Stderr); len(name) > 0 {
+				return "command not found: " + name
+			}
+			return "command not found"
+		}
+		lines := strings.Split(strings.TrimSpace(cmdErr.Stderr), "\n")
+		if last := strings.TrimSpace(lines[len(lines)-1]); len(last) > 0 {
+			return last
+		}
+		return fmt.Sprintf("exit status %d", cmdErr.Status)
+	}
+	return e.Err.Error()
+}
+
+// missingCommand names the command the shell could not find, from its message
+// such as "sh: 1: ip: not found" or, failing that, as the first word of command
+// past variable assignments like PATH=...;
+func missingCommand(command, stderr string) string {
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if _, name, ok := strings.Cut(line, "command not found: "); ok { // zsh
+			return name
+		}
+		for _, suffix := range []string{": command not found", ": not found"} {
+			if rest, ok := strings.CutSuffix(line, suffix); ok {
+				if i := strings.LastIndex(rest, ": "); i >= 0 {
+					rest = rest[i+2:]
+				}
+				return rest
+			}
+		}
+	}
+	for _, word := range strings.FieldsFunc(command, func(r rune) bool { return r == ' ' || r == '\t' || r == ';' }) {
+		if !strings.Contains(word, "=") {
+			return word
+		}
+	}
+	return ""
+}
@@ -0,0 +1,34 @@
+package stats
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCollectorErrorReason(t *testing.T) {
+	notFound := func(command, stderr string) error {
+		return &CommandError{Command: command, Status: 127, Stderr: stderr, Err: errors.New("exit status 127")}
+	}
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{notFound("PATH=$PATH:/sbin:/usr/sbin; ip -o addr", "sh: 1: ip: not found\n"), "command not found: ip"},
+		{notFound("PATH=$PATH:/sbin:/usr/sbin; ip -o addr", "sh: ip: not found\n"), "command not found: ip"},
+		{notFound("df -Pk", "bash: line 1: df: command not found\n"), "command not found: df"},
+		{notFound("df -Pk", "zsh:1: command not found: df\n"), "command not found: df"},
+		{notFound("PATH=$PATH:/sbin:/usr/sbin; ip -o addr", ""), "command not found: ip"},
+		{notFound("LC_ALL=C FOO=1 stat -f /", "\n"), "command not found: stat"},
+		{notFound("", ""), "command not found"},
+		{&CommandError{Command: "cat /proc/x", Status: 1, Stderr: "warming up\ncat: /proc/x: No such file or directory\n"}, "cat: /proc/x: No such file or directory"},
+		{&CommandError{Command: "false", Status: 1}, "exit status 1"},
+		{&ConnectionError{errors.New("EOF")}, "connection lost"},
+		{errors.New("bad output"), "bad output"},
+	}
+	for _, tt := range tests {
+		e := &CollectorError{Collector: "interfaces", Err: tt.err}
+		if got := e.Reason(); got != tt.want {
+			t.Errorf("Reason() of %v = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package stats
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/0x0BSoD/rtop/pkg/logger"
+)
+
+// ExecTransport runs commands through the local OpenSSH client, so the
+// whole of ssh_config (ControlMaster, GSSAPI, FIDO keys, ProxyJump, ...)
+// applies without rtop having to understand it.
+type ExecTransport struct {
+	Binary  string // ssh executable, looked up in PATH
+	Host    string // host or ssh_config alias, passed through untouched
+	Port    int    // 0 leaves the port to ssh_config
+	User    string // empty leaves the user to ssh_config
+	KeyPath string // empty leaves identities to ssh_config
+
+	controlDir string
+}
+
+func NewExecTransport(user, host string, port int, keyPath string) (*ExecTransport, error) {
+	// ssh reads a destination like -oProxyCommand=... as an option, and host
+	// names may come from inventory files
+	if len(host) == 0 || strings.HasPrefix(host, "-") {
+		return nil, fmt.Errorf("bad host name %q", host)
+	}
+	t := &ExecTransport{
+		Binary:  "ssh",
+		Host:    host,
+		Port:    port,
+		User:    user,
+		KeyPath: keyPath,
+	}
+	if _, err := exec.LookPath(t.Binary); err != nil {
+		return nil, fmt.Errorf("ssh client not found: %w", err)
+	}
+
+	// Every collector runs its own command, so without connection sharing
+	// each one would pay for a full handshake
+	if runtime.GOOS != "windows" && !t.multiplexed() {
+		dir, err := os.MkdirTemp("", "rtop-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create control socket directory: %w", err)
+		}
+		t.controlDir = dir
+		logger.Debug("Sharing ssh connections through %s", dir)
+	}
+	return t, nil
+}
+
+// multiplexed reports whether ssh_config already sets up ControlMaster for
+// the host, in which case its socket is reused as-is
+func (t *ExecTransport) multiplexed() bool {
+	out, err := exec.Command(t.Binary, append(t.args(), "-G", t.Host)...).Output()
+	if err != nil {
+		logger.Debug("ssh -G %s failed: %v", t.Host, err)
+		return false
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) == 2 && parts[0] == "controlmaster" {
+			return parts[1] != "false" && parts[1] != "no"
+		}
+	}
+	return false
+}
+
+func (t *ExecTransport) args() []string {
+	args := []string{"-o", "BatchMode=yes"}
+	if DialTimeout > 0 {
+		args = append(args, "-o", fmt.Sprintf("ConnectTimeout=%d", int(DialTimeout.Seconds())))
+	}
+	if KeepaliveInterval > 0 {
+		args = append(args,
+			"-o", fmt.Sprintf("ServerAliveInterval=%d", int(KeepaliveInterval.Seconds())),
+			"-o", fmt.Sprintf("ServerAliveCountMax=%d", KeepaliveCountMax),
+		)
+	}
+	if t.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(t.Port))
+	}
+	if len(t.User) > 0 {
+		args = append(args, "-l", t.User)
+	}
+	if len(t.KeyPath) > 0 {
+		args = append(args, "-i", t.KeyPath)
+	}
+	if len(t.controlDir) > 0 {
+		args = append(args,
+			"-o", "ControlMaster=auto",
+			"-o", "ControlPath="+filepath.Join(t.controlDir, "%C"),
+			"-o", "ControlPersist=yes",
+		)
+	}
+	return args
+}
+
+func (t *ExecTransport) RunCommand(ctx context.Context, command string) (string, error) {
+	return t.RunCommandInput(ctx, command, nil)
+}
+
+func (t *ExecTransport) RunCommandInput(ctx context.Context, command string, stdin io.Reader) (string, error) {
+	if CommandTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, CommandTimeout)
+		defer cancel()
+	}
+
+	logger.Debug("Executing command via %s: %s", t.Binary, command)
+	args := append(t.args(), "-T", t.Host, "--", command)
+	cmd := exec.CommandContext(ctx, t.Binary, args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		logger.Error("Command execution failed: %s - %v: %s", command, err, strings.TrimSpace(stderr.String()))
+		cmdErr := &CommandError{Command: command, Status: -1, Stderr: stderr.String(), Err: err}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			cmdErr.Status = exitErr.ExitCode()
+		}
+		// ssh reserves exit status 255 for its own failures
+		if cmdErr.Status == 255 {
+			return "", &ConnectionError{cmdErr}
+		}
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("command '%s' did not finish: %w", command, ctx.Err())
+		}
+		return "", cmdErr
+	}
+
+	output := stdout.String()
+	logger.Debug("Command executed successfully, output length: %d bytes", len(output))
+	return output, nil
+}
+
+// Close stops the master connection rtop started, if any
+func (t *ExecTransport) Close() error {
+	if len(t.controlDir) == 0 {
+		return nil
+	}
+	defer os.RemoveAll(t.controlDir)
+	return exec.Command(t.Binary, append(t.args(), "-O", "exit", t.Host)...).Run()
+}
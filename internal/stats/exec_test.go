@@ -0,0 +1,137 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stubSSH puts an ssh on PATH that runs the remote command locally, answers
+// ssh -G with the controlmaster given and logs its arguments
+const stubSSH = `#!/bin/sh
+echo "$@" >> "$STUB_LOG"
+for last; do :; done
+case " $* " in
+*" -G "*) echo "controlmaster $STUB_CONTROLMASTER"; exit 0 ;;
+*" -O exit "*) exit 0 ;;
+esac
+exec /bin/sh -c "$last"
+`
+
+func stubSSHPath(t *testing.T, controlmaster string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ssh"), []byte(stubSSH), 0755); err != nil {
+		t.Fatal(err)
+	}
+	log := filepath.Join(dir, "log")
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("STUB_LOG", log)
+	t.Setenv("STUB_CONTROLMASTER", controlmaster)
+	return log
+}
+
+func TestExecTransport(t *testing.T) {
+	tests := []struct {
+		name          string
+		controlmaster string
+		command       string
+		out           string
+		status        int // of a CommandError, 255 for a ConnectionError
+	}{
+		{name: "output", controlmaster: "false", command: "echo hello; echo world", out: "hello\nworld\n"},
+		{name: "own master", controlmaster: "auto", command: "printf x", out: "x"},
+		{name: "command failed", controlmaster: "false", command: "echo oops >&2; exit 3", status: 3},
+		{name: "ssh failed", controlmaster: "false", command: "exit 255", status: 255},
+	}
+	for _, tt := range tests {
+		log := stubSSHPath(t, tt.controlmaster)
+		transport, err := NewExecTransport("admin", "web1", 2222, "/keys/id")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		shared := len(transport.controlDir) > 0
+		if shared != (tt.controlmaster == "false") {
+			t.Errorf("%s: sharing connections = %v with controlmaster %s", tt.name, shared, tt.controlmaster)
+		}
+
+		out, err := transport.RunCommand(context.Background(), tt.command)
+		var cmdErr *CommandError
+		var connErr *ConnectionError
+		switch {
+		case tt.status == 0 && (err != nil || out != tt.out):
+			t.Errorf("%s: got %q, %v, want %q", tt.name, out, err, tt.out)
+		case tt.status == 255 && !errors.As(err, &connErr):
+			t.Errorf("%s: got %v, want a ConnectionError", tt.name, err)
+		case tt.status != 0 && tt.status != 255 && (errors.As(err, &connErr) || !errors.As(err, &cmdErr) || cmdErr.Status != tt.status || cmdErr.Stderr != "oops\n"):
+			t.Errorf("%s: got %#v, want a CommandError with status %d", tt.name, err, tt.status)
+		}
+
+		if err := transport.Close(); err != nil {
+			t.Errorf("%s: close: %v", tt.name, err)
+		}
+		if shared {
+			if _, err := os.Stat(transport.controlDir); !os.IsNotExist(err) {
+				t.Errorf("%s: control directory left behind", tt.name)
+			}
+		}
+
+		data, err := os.ReadFile(log)
+		if err != nil {
+			t.Fatal(err)
+		}
+		calls := strings.Split(strings.TrimSpace(string(data)), "\n")
+		run := calls[1]
+		for _, arg := range []string{"-o BatchMode=yes", "-p 2222", "-l admin", "-i /keys/id", "-T web1 -- "} {
+			if !strings.Contains(run, arg) {
+				t.Errorf("%s: ssh %s lacks %s", tt.name, run, arg)
+			}
+		}
+		if strings.Contains(run, "ControlPath=") != shared {
+			t.Errorf("%s: ssh %s, sharing connections = %v", tt.name, run, shared)
+		}
+		if stopped := strings.Contains(calls[len(calls)-1], "-O exit web1"); stopped != shared {
+			t.Errorf("%s: master stopped = %v, want %v", tt.name, stopped, shared)
+		}
+	}
+}
+
+func TestCollectOverExecTransport(t *testing.T) {
+	stubSSHPath(t, "false")
+	transport, err := NewExecTransport("", "web1", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+
+	s := NewSshFetcher(transport)
+	s.Exclude = map[string]bool{"filesystems": true, "interface-info": true, "cgroups": true}
+	if errs := s.GetAllStats(context.Background()); len(errs) > 0 {
+		t.Fatalf("collecting the local host: %v", errs)
+	}
+	if s.Stats.MemTotal == 0 || s.Stats.Uptime == 0 || len(s.Stats.Hostname) == 0 {
+		t.Errorf("missing stats of the local host: %+v", s.Stats)
+	}
+}
+
+func TestExecTransportBadHost(t *testing.T) {
+	log := stubSSHPath(t, "false")
+	for _, host := range []string{"-oProxyCommand=touch /tmp/pwned", "-G", ""} {
+		if _, err := NewExecTransport("", host, 0, ""); err == nil {
+			t.Errorf("NewExecTransport(%q) did not fail", host)
+		}
+	}
+	if _, err := os.Stat(log); !os.IsNotExist(err) {
+		t.Error("ssh ran for a bad host name")
+	}
+}
+
+func TestExecTransportWithoutSSH(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	if _, err := NewExecTransport("", "web1", 0, ""); err == nil {
+		t.Error("NewExecTransport did not fail without ssh on PATH")
+	}
+}
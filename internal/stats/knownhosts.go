@@ -0,0 +1,67 @@
+package stats
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/0x0BSoD/rtop/pkg/logger"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func knownHostsFiles() []string {
+	var files []string
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(homeDir, ".ssh", "known_hosts"))
+	}
+	files = append(files, "/etc/ssh/ssh_known_hosts")
+
+	existing := make([]string, 0, len(files))
+	for _, f := range files {
+		if _, err := os.Stat(f); err == nil {
+			existing = append(existing, f)
+		}
+	}
+	return existing
+}
+
+// hostKeyCallback verifies server keys against the known_hosts files,
+// including host certificates signed by a @cert-authority. Hosts missing
+// from known_hosts are accepted with a warning, as rtop never writes there.
+func hostKeyCallback() ssh.HostKeyCallback {
+	files := knownHostsFiles()
+	if len(files) == 0 {
+		logger.Warn("No known_hosts file found, host keys will not be verified")
+		return ssh.InsecureIgnoreHostKey()
+	}
+
+	check, err := knownhosts.New(files...)
+	if err != nil {
+		logger.Warn("Failed to load known hosts, host keys will not be verified: %v", err)
+		return ssh.InsecureIgnoreHostKey()
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := check(hostname, remote, key)
+		// Without a matching CA fall back to the plain key, like ssh(1). The
+		// error is the only way x/crypto tells that no CA matched.
+		if cert, ok := key.(*ssh.Certificate); ok && err != nil && strings.HasPrefix(err.Error(), "ssh: no authorities for hostname") {
+			logger.Debug("No matching @cert-authority for %s, checking plain host key", hostname)
+			key = cert.Key
+			err = check(hostname, remote, key)
+		}
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+			logger.Warn("Host %s is not in known_hosts, accepting %s key %s",
+				hostname, key.Type(), ssh.FingerprintSHA256(key))
+			return nil
+		}
+		if err != nil {
+			logger.Error("Host key verification failed for %s: %v", hostname, err)
+		}
+		return err
+	}
+}
@@ -0,0 +1,82 @@
+package stats
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func newSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func hostCert(t *testing.T, ca ssh.Signer, key ssh.PublicKey, principals ...string) *ssh.Certificate {
+	t.Helper()
+	cert := &ssh.Certificate{
+		Key:             key,
+		CertType:        ssh.HostCert,
+		ValidPrincipals: principals,
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestHostKeyCallback(t *testing.T) {
+	ca, otherCA := newSigner(t), newSigner(t)
+	hostKey, pinnedKey, changedKey := newSigner(t).PublicKey(), newSigner(t).PublicKey(), newSigner(t).PublicKey()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	os.Mkdir(filepath.Join(home, ".ssh"), 0700)
+	knownHosts := strings.Join([]string{
+		"@cert-authority *.example.com,!bad.example.com " + strings.TrimSpace(string(ssh.MarshalAuthorizedKey(ca.PublicKey()))),
+		"pinned.example.org " + strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pinnedKey))),
+		"bad.example.com " + strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pinnedKey))),
+	}, "\n") + "\n"
+	if err := os.WriteFile(filepath.Join(home, ".ssh", "known_hosts"), []byte(knownHosts), 0600); err != nil {
+		t.Fatal(err)
+	}
+	callback := hostKeyCallback()
+	remote := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22}
+
+	tests := []struct {
+		name string
+		host string
+		key  ssh.PublicKey
+		ok   bool
+	}{
+		{"certificate from the CA", "web1.example.com:22", hostCert(t, ca, hostKey, "web1.example.com"), true},
+		{"certificate for another host", "db1.example.com:22", hostCert(t, ca, hostKey, "web1.example.com"), false},
+		{"CA negated for the host", "bad.example.com:22", hostCert(t, ca, hostKey, "bad.example.com"), false},
+		{"CA negated, plain key pinned", "bad.example.com:22", hostCert(t, ca, pinnedKey, "bad.example.com"), true},
+		{"unknown CA, plain key pinned", "pinned.example.org:22", hostCert(t, otherCA, pinnedKey, "pinned.example.org"), true},
+		{"unknown CA, plain key changed", "pinned.example.org:22", hostCert(t, otherCA, changedKey, "pinned.example.org"), false},
+		{"plain key pinned", "pinned.example.org:22", pinnedKey, true},
+		{"plain key changed", "pinned.example.org:22", changedKey, false},
+		{"host not known", "new.example.org:22", hostKey, true},
+	}
+	for _, tt := range tests {
+		err := callback(tt.host, remote, tt.key)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: %v, want accepted %v", tt.name, err, tt.ok)
+		}
+	}
+}
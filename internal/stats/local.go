@@ -0,0 +1,53 @@
+package stats
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/0x0BSoD/rtop/pkg/logger"
+)
+
+// LocalTransport runs commands on this host, to collect its own stats
+type LocalTransport struct{}
+
+func (t LocalTransport) RunCommand(ctx context.Context, command string) (string, error) {
+	return t.RunCommandInput(ctx, command, nil)
+}
+
+func (t LocalTransport) RunCommandInput(ctx context.Context, command string, stdin io.Reader) (string, error) {
+	if CommandTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, CommandTimeout)
+		defer cancel()
+	}
+
+	logger.Debug("Executing command locally: %s", command)
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		logger.Error("Command execution failed: %s - %v: %s", command, err, strings.TrimSpace(stderr.String()))
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("command '%s' did not finish: %w", command, ctx.Err())
+		}
+		cmdErr := &CommandError{Command: command, Status: -1, Stderr: stderr.String(), Err: err}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			cmdErr.Status = exitErr.ExitCode()
+		}
+		return "", cmdErr
+	}
+	return stdout.String(), nil
+}
+
+func (t LocalTransport) Close() error {
+	return nil
+}
@@ -0,0 +1,231 @@
+package stats
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/0x0BSoD/rtop/pkg/logger"
+)
+
+// Capabilities is what the probe found out about the remote host
+type Capabilities struct {
+	OS            string
+	Kernel        string
+	CgroupVersion int               // 0 when no cgroup filesystem is mounted
+	Binaries      map[string]string // name to path, empty when missing
+	Readable      map[string]bool   // /proc and /sys files the collectors read
+	Sudo          bool              // passwordless sudo works
+}
+
+var probedBinaries = []string{"ip", "df", "stat", "cat", "ss", "systemctl", "journalctl", "hostname", "find", "sudo"}
+
+var probedFiles = []string{
+	"/proc/uptime",
+	"/proc/loadavg",
+	"/proc/meminfo",
+	"/proc/stat",
+	"/proc/net/dev",
+	"/proc/net/route",
+	"/proc/net/if_inet6",
+	"/proc/self/mountinfo",
+	"/proc/sys/kernel/hostname",
+	"/sys/class/net",
+	"/sys/fs/cgroup",
+}
+
+// probeScript gathers everything in a single round trip. It sticks to POSIX
+// sh so it runs under BusyBox too; ip and friends often live in sbin
+// directories that are not in PATH for normal users.
+func probeScript() string {
+	return fmt.Sprintf(`if command -v uname >/dev/null 2>&1; then
+	echo "os $(uname -s)"
+	echo "kernel $(uname -r)"
+else
+	read -r o < /proc/sys/kernel/ostype; echo "os $o"
+	read -r r < /proc/sys/kernel/osrelease; echo "kernel $r"
+fi
+for b in %s; do
+	p=$(command -v $b 2>/dev/null)
+	for d in /sbin /usr/sbin; do [ -z "$p" ] && [ -x $d/$b ] && p=$d/$b; done
+	echo "bin $b $p"
+done
+for f in %s; do [ -r $f ] && echo "readable $f"; done
+if [ -f /sys/fs/cgroup/cgroup.controllers ]; then echo "cgroup 2"; elif [ -d /sys/fs/cgroup/memory ]; then echo "cgroup 1"; fi
+sudo -n true 2>/dev/null && echo "sudo yes"
+true`, strings.Join(probedBinaries, " "), strings.Join(probedFiles, " "))
+}
+
+func parseProbe(out string) *Capabilities {
+	caps := &Capabilities{
+		Binaries: make(map[string]string),
+		Readable: make(map[string]bool),
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) < 2 {
+			continue
+		}
+		switch parts[0] {
+		case "os":
+			caps.OS = parts[1]
+		case "kernel":
+			caps.Kernel = parts[1]
+		case "bin":
+			if len(parts) == 3 {
+				caps.Binaries[parts[1]] = parts[2]
+			} else {
+				caps.Binaries[parts[1]] = ""
+			}
+		case "readable":
+			caps.Readable[parts[1]] = true
+		case "cgroup":
+			caps.CgroupVersion, _ = strconv.Atoi(parts[1])
+		case "sudo":
+			caps.Sudo = parts[1] == "yes"
+		}
+	}
+	return caps
+}
+
+// requirement returns why a collector cannot work on a host, or ""
+type requirement func(caps *Capabilities) string
+
+func needBinary(name string) requirement {
+	return func(caps *Capabilities) string {
+		if len(caps.Binaries[name]) == 0 {
+			return name + " not found"
+		}
+		return ""
+	}
+}
+
+func needReadable(path string) requirement {
+	return func(caps *Capabilities) string {
+		if !caps.Readable[path] {
+			return path + " not readable"
+		}
+		return ""
+	}
+}
+
+// anyOf is met when one of the requirements is
+func anyOf(reqs ...requirement) requirement {
+	return func(caps *Capabilities) string {
+		reasons := make([]string, 0, len(reqs))
+		for _, req := range reqs {
+			reason := req(caps)
+			if len(reason) == 0 {
+				return ""
+			}
+			reasons = append(reasons, reason)
+		}
+		return strings.Join(reasons, ", ")
+	}
+}
+
+func needCgroupV2(caps *Capabilities) string {
+	switch caps.CgroupVersion {
+	case 2:
+		return ""
+	case 0:
+		return "no cgroup filesystem"
+	default:
+		return fmt.Sprintf("cgroup v%d not supported", caps.CgroupVersion)
+	}
+}
+
+// Probe inspects the host once after connecting and decides which collectors
+// can run there. Only Linux hosts are supported.
+func (s *SshFetcher) Probe(ctx context.Context) error {
+	logger.Debug("Probing remote host capabilities")
+	out, err := s.Transport.RunCommand(ctx, probeScript())
+	if err != nil {
+		return fmt.Errorf("failed to probe host: %w", err)
+	}
+	caps := parseProbe(out)
+
+	logger.Info("Remote OS detected: %s %s", caps.OS, caps.Kernel)
+	if !strings.EqualFold(caps.OS, "Linux") {
+		return fmt.Errorf("rtop not supported for %s system", caps.OS)
+	}
+
+	disabled := make(map[string]string)
+	for _, c := range collectors {
+		for _, req := range c.requires {
+			if reason := req(caps); len(reason) > 0 {
+				disabled[c.name] = reason
+				logger.Warn("Collector %s disabled: %s", c.name, reason)
+				break
+			}
+		}
+	}
+
+	s.Caps = caps
+	s.disabled = disabled
+	return nil
+}
+
+// CollectorState tells whether a collector runs on the host and why not
+type CollectorState struct {
+	Name    string
+	Enabled bool
+	Reason  string
+}
+
+func (s *SshFetcher) CollectorStates() []CollectorState {
+	states := make([]CollectorState, 0, len(collectors))
+	for _, c := range collectors {
+		reason, off := s.disabled[c.name]
+		if s.Exclude[c.name] {
+			reason, off = "excluded", true
+		}
+		states = append(states, CollectorState{Name: c.name, Enabled: !off, Reason: reason})
+	}
+	return states
+}
+
+// Report renders the capabilities as text, one fact per line
+func (caps *Capabilities) Report() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "OS: %s %s\n", caps.OS, caps.Kernel)
+	if caps.CgroupVersion == 0 {
+		sb.WriteString("Cgroups: none\n")
+	} else {
+		fmt.Fprintf(&sb, "Cgroups: v%d\n", caps.CgroupVersion)
+	}
+	sudo := "no"
+	if caps.Sudo {
+		sudo = "yes"
+	}
+	fmt.Fprintf(&sb, "Passwordless sudo: %s\n", sudo)
+
+	sb.WriteString("\nBinaries:\n")
+	names := make([]string, 0, len(caps.Binaries))
+	for name := range caps.Binaries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := caps.Binaries[name]
+		if len(path) == 0 {
+			path = "missing"
+		}
+		fmt.Fprintf(&sb, "  %-12s %s\n", name, path)
+	}
+
+	sb.WriteString("\nFiles:\n")
+	for _, f := range probedFiles {
+		state := "not readable"
+		if caps.Readable[f] {
+			state = "readable"
+		}
+		fmt.Fprintf(&sb, "  %-26s %s\n", f, state)
+	}
+	return sb.String()
+}
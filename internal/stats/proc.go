@@ -0,0 +1,302 @@
+package stats
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Minimal images (BusyBox, NixOS, distroless-style) cannot be relied on to
+// ship coreutils or iproute2 at fixed paths, so collectors read /proc and /sys
+// where they can and only fall back to utilities found in PATH.
+
+// readCommand prints a file with cat, or with shell builtins alone on hosts
+// that have no cat at all
+func readCommand(path string) string {
+	p := shellQuote(path)
+	return fmt.Sprintf(`if command -v cat >/dev/null 2>&1; then cat %s; else while IFS= read -r l || [ -n "$l" ]; do printf '%%s\n' "$l"; done < %s; fi`, p, p)
+}
+
+// sectionsCommand runs every file through readCommand in one go, each preceded by a
+// marker line, see splitSections
+func sectionsCommand(paths ...string) string {
+	cmds := make([]string, 0, len(paths))
+	for _, p := range paths {
+		cmds = append(cmds, fmt.Sprintf("echo %s; %s", shellQuote("==> "+p), readCommand(p)))
+	}
+	return strings.Join(cmds, "; ")
+}
+
+func splitSections(out string) map[string]string {
+	sections := make(map[string]string)
+	var name string
+	var sb strings.Builder
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "==> ") {
+			if len(name) > 0 {
+				sections[name] = sb.String()
+			}
+			name = strings.TrimPrefix(line, "==> ")
+			sb.Reset()
+			continue
+		}
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+	if len(name) > 0 {
+		sections[name] = sb.String()
+	}
+	return sections
+}
+
+// mount is an entry of /proc/self/mountinfo
+type mount struct {
+	Source     string
+	MountPoint string
+	FSType     string
+}
+
+// unescapeMountinfo decodes the octal escapes the kernel uses for spaces,
+// tabs, newlines and backslashes in paths
+func unescapeMountinfo(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				sb.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// parseMountinfo returns the block device mounts, the same ones df lists. A
+// mount point mounted over keeps only its topmost entry.
+func parseMountinfo(lines string) []mount {
+	var mounts []mount
+	index := make(map[string]int)
+
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		sep := -1
+		for i, f := range fields {
+			if f == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 5 || sep+2 >= len(fields) {
+			continue
+		}
+		m := mount{
+			Source:     unescapeMountinfo(fields[sep+2]),
+			MountPoint: unescapeMountinfo(fields[4]),
+			FSType:     fields[sep+1],
+		}
+		if !strings.HasPrefix(m.Source, "/dev/") {
+			continue
+		}
+		if i, ok := index[m.MountPoint]; ok {
+			mounts[i] = m
+			continue
+		}
+		index[m.MountPoint] = len(mounts)
+		mounts = append(mounts, m)
+	}
+	return mounts
+}
+
+// statfsCommand prints block size, blocks, free blocks, available blocks and
+// the name for every path, one line each
+func statfsCommand(paths []string) string {
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = shellQuote(p)
+	}
+	return "stat -f -c '%S %b %f %a %n' -- " + strings.Join(quoted, " ")
+}
+
+func parseStatfs(lines string, mounts []mount) []FSInfo {
+	sources := make(map[string]string, len(mounts))
+	for _, m := range mounts {
+		sources[m.MountPoint] = m.Source
+	}
+
+	var infos []FSInfo
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), " ", 5)
+		if len(parts) != 5 {
+			continue
+		}
+		var vals [4]uint64
+		ok := true
+		for i := range vals {
+			v, err := strconv.ParseUint(parts[i], 10, 64)
+			if err != nil {
+				ok = false
+				break
+			}
+			vals[i] = v
+		}
+		source, known := sources[parts[4]]
+		if !ok || !known {
+			continue
+		}
+		bsize, blocks, free, avail := vals[0], vals[1], vals[2], vals[3]
+		infos = append(infos, FSInfo{
+			Device:     source,
+			MountPoint: parts[4],
+			Used:       (blocks - free) * bsize,
+			Free:       avail * bsize,
+		})
+	}
+	return infos
+}
+
+// parseDf reads POSIX df -P output, whose sizes are in units of unit bytes
+func parseDf(lines string, unit uint64) []FSInfo {
+	var infos []FSInfo
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	flag := 0
+	var device string // of a line wrapped after a long device name
+	for scanner.Scan() {
+		line := scanner.Text()
+		parts := strings.Fields(line)
+		n := len(parts)
+		dev := n > 0 && strings.Index(parts[0], "/dev/") == 0
+		if n == 1 && dev {
+			flag = 1
+			device = parts[0]
+		} else if (n == 5 && flag == 1) || (n == 6 && dev) {
+			i := flag
+			flag = 0
+			if i == 0 {
+				device = parts[0]
+			}
+			used, err := strconv.ParseUint(parts[2-i], 10, 64)
+			if err != nil {
+				continue
+			}
+			free, err := strconv.ParseUint(parts[3-i], 10, 64)
+			if err != nil {
+				continue
+			}
+			infos = append(infos, FSInfo{
+				device, parts[5-i], used * unit, free * unit,
+			})
+		}
+	}
+	return infos
+}
+
+// parseIfInet6 maps interfaces to an IPv6 address with prefix length from
+// /proc/net/if_inet6, the last address of an interface wins like with ip
+func parseIfInet6(lines string) map[string]string {
+	addrs := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 6 {
+			continue
+		}
+		raw, err := hex.DecodeString(fields[0])
+		if err != nil || len(raw) != net.IPv6len {
+			continue
+		}
+		plen, err := strconv.ParseUint(fields[2], 16, 8)
+		if err != nil {
+			continue
+		}
+		addrs[fields[5]] = fmt.Sprintf("%s/%d", net.IP(raw), plen)
+	}
+	return addrs
+}
+
+// parseFibTrie lists the local IPv4 addresses in /proc/net/fib_trie, which
+// are the ones followed by a "/32 host LOCAL" leaf
+func parseFibTrie(lines string) []net.IP {
+	var local []net.IP
+	seen := make(map[string]bool)
+	var last string
+
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "|-- ") {
+			last = strings.TrimPrefix(line, "|-- ")
+			continue
+		}
+		if strings.HasPrefix(line, "/32 host LOCAL") && !seen[last] {
+			if ip := net.ParseIP(last).To4(); ip != nil {
+				seen[last] = true
+				local = append(local, ip)
+			}
+		}
+	}
+	return local
+}
+
+type route struct {
+	Iface string
+	Net   net.IPNet
+}
+
+// parseRoutes reads the directly connected networks from /proc/net/route,
+// where addresses are little endian hex
+func parseRoutes(lines string) []route {
+	var routes []route
+	scanner := bufio.NewScanner(strings.NewReader(lines))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[2] != "00000000" {
+			continue
+		}
+		dest, err1 := strconv.ParseUint(fields[1], 16, 32)
+		mask, err2 := strconv.ParseUint(fields[7], 16, 32)
+		if err1 != nil || err2 != nil || mask == 0 {
+			continue
+		}
+		ipnet := net.IPNet{IP: make(net.IP, net.IPv4len), Mask: make(net.IPMask, net.IPv4len)}
+		binary.LittleEndian.PutUint32(ipnet.IP, uint32(dest))
+		binary.LittleEndian.PutUint32(ipnet.Mask, uint32(mask))
+		routes = append(routes, route{Iface: fields[0], Net: ipnet})
+	}
+	return routes
+}
+
+// ipv4Addrs pairs local addresses with the interface of the most specific
+// connected network containing them. Loopback addresses live in the local
+// table only and go to lo.
+func ipv4Addrs(local []net.IP, routes []route) map[string]string {
+	addrs := make(map[string]string)
+	for _, ip := range local {
+		best, bestLen := "", -1
+		for _, r := range routes {
+			if ones, _ := r.Net.Mask.Size(); r.Net.Contains(ip) && ones > bestLen {
+				best, bestLen = r.Iface, ones
+			}
+		}
+		if bestLen < 0 && ip.IsLoopback() {
+			best, bestLen = "lo", 8
+		}
+		if bestLen < 0 {
+			continue
+		}
+		addrs[best] = fmt.Sprintf("%s/%d", ip, bestLen)
+	}
+	return addrs
+}
@@ -0,0 +1,159 @@
+package stats
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fixture reads a file of testdata, captured from BusyBox and Alpine hosts
+func fixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestParseMountinfo(t *testing.T) {
+	want := []mount{
+		{Source: "/dev/sda1", MountPoint: "/", FSType: "ext4"},
+		{Source: "/dev/sdb1", MountPoint: "/mnt/my disk", FSType: "xfs"},
+		{Source: "/dev/sdd1", MountPoint: "/var/lib/docker", FSType: "ext4"}, // mounted over sdc1
+	}
+	if got := parseMountinfo(fixture(t, "mountinfo")); !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestUnescapeMountinfo(t *testing.T) {
+	for s, want := range map[string]string{
+		`/plain`:               "/plain",
+		`/a\040b\011c\012d`:    "/a b\tc\nd",
+		`/back\134slash`:       `/back\slash`,
+		`/not\999octal\04`:     `/not\999octal\04`,
+		`/trailing\`:           `/trailing\`,
+		`/\040\040two\040lead`: "/  two lead",
+	} {
+		if got := unescapeMountinfo(s); got != want {
+			t.Errorf("unescapeMountinfo(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestParseStatfs(t *testing.T) {
+	want := []FSInfo{
+		{Device: "/dev/sda1", MountPoint: "/", Used: 600 * 4096, Free: 300 * 4096},
+		{Device: "/dev/sdb1", MountPoint: "/mnt/my disk", Used: 1000 * 4096, Free: 1000 * 4096},
+		{Device: "/dev/sdd1", MountPoint: "/var/lib/docker", Used: 50 * 1024, Free: 40 * 1024},
+	}
+	got := parseStatfs(fixture(t, "statfs"), parseMountinfo(fixture(t, "mountinfo")))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%+v\nwant\n%+v", got, want)
+	}
+	if cmd := statfsCommand([]string{"/", "/mnt/it's"}); cmd != `stat -f -c '%S %b %f %a %n' -- '/' '/mnt/it'\''s'` {
+		t.Errorf("statfsCommand quoted as %s", cmd)
+	}
+}
+
+func TestParseDf(t *testing.T) {
+	want := []FSInfo{
+		{Device: "/dev/sda1", MountPoint: "/", Used: 8934400 * 1024, Free: 30104800 * 1024},
+		{Device: "/dev/mapper/vg-very-long-logical-volume-name", MountPoint: "/srv", Used: 1048576 * 1024, Free: 9426944 * 1024},
+	}
+	if got := parseDf(fixture(t, "df"), 1024); !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParseIfInet6(t *testing.T) {
+	want := map[string]string{"lo": "::1/128", "eth0": "2001:db8::42/64"}
+	if got := parseIfInet6(fixture(t, "if_inet6")); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestIPv4Addrs(t *testing.T) {
+	local := parseFibTrie(fixture(t, "fib_trie"))
+	var ips []string
+	for _, ip := range local {
+		ips = append(ips, ip.String())
+	}
+	if want := []string{"10.0.0.5", "127.0.0.1", "172.17.0.1"}; !reflect.DeepEqual(ips, want) {
+		t.Errorf("local addresses %v, want %v", ips, want)
+	}
+
+	routes := parseRoutes(fixture(t, "route"))
+	var nets []string
+	for _, r := range routes {
+		nets = append(nets, r.Iface+" "+r.Net.String())
+	}
+	if want := []string{"eth0 10.0.0.0/24", "docker0 172.17.0.0/16", "eth0 10.0.0.0/16"}; !reflect.DeepEqual(nets, want) {
+		t.Errorf("routes %v, want %v", nets, want)
+	}
+
+	want := map[string]string{"eth0": "10.0.0.5/24", "lo": "127.0.0.1/8", "docker0": "172.17.0.1/16"}
+	if got := ipv4Addrs(local, routes); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestGetInterfacesProc(t *testing.T) {
+	out := "lo\neth0\ndocker0\nwlan0\n" +
+		"==> /proc/net/if_inet6\n" + fixture(t, "if_inet6") +
+		"==> /proc/net/fib_trie\n" + fixture(t, "fib_trie") +
+		"==> /proc/net/route\n" + fixture(t, "route")
+	client := &fakeTransport{replies: []reply{{key: "/sys/class/net", out: out}}}
+	st := &Stats{}
+	if err := getInterfacesProc(context.Background(), client, st); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]NetIntfInfo{
+		"lo":      {IPv4: "127.0.0.1/8", IPv6: "::1/128"},
+		"eth0":    {IPv4: "10.0.0.5/24", IPv6: "2001:db8::42/64"},
+		"docker0": {IPv4: "172.17.0.1/16"},
+	}
+	if !reflect.DeepEqual(st.NetIntf, want) {
+		t.Errorf("got %+v, want %+v", st.NetIntf, want)
+	}
+}
+
+func TestReadCommandWithoutCat(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "it's a file")
+	content := "  leading spaces\nback\\slash\n\nno newline at the end"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var client LocalTransport
+	for _, prefix := range []string{"", "PATH=" + dir + "; "} {
+		out, err := client.RunCommand(context.Background(), prefix+readCommand(path))
+		if err != nil {
+			t.Fatalf("%q: %v", prefix, err)
+		}
+		want := content
+		if len(prefix) > 0 {
+			want += "\n" // the shell loop ends every line
+		}
+		if out != want {
+			t.Errorf("%q: read %q, want %q", prefix, out, want)
+		}
+	}
+
+	out, err := client.RunCommand(context.Background(), "PATH="+dir+"; "+sectionsCommand(path, "/dev/null"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{path: content + "\n", "/dev/null": ""}
+	if got := splitSections(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("sections %q, want %q", got, want)
+	}
+	if !strings.HasPrefix(out, "==> ") {
+		t.Errorf("sections start with %q", out)
+	}
+}
@@ -0,0 +1,129 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/0x0BSoD/rtop/pkg/logger"
+)
+
+const (
+	minBackoff = 1 * time.Second
+	maxBackoff = 1 * time.Minute
+)
+
+// connState tracks a lost connection between collection rounds
+type connState struct {
+	lost        bool
+	attempts    int
+	backoff     time.Duration
+	nextAttempt time.Time
+	lastErr     error
+	lastUpdate  time.Time
+}
+
+// ConnStatus describes the connection for display
+type ConnStatus struct {
+	Reconnecting bool
+	Attempts     int
+	NextAttempt  time.Time
+	LastError    error
+	LastUpdate   time.Time // when Stats were last collected in full
+}
+
+var errWaitingToReconnect = errors.New("waiting to reconnect")
+
+func (s *SshFetcher) Status() ConnStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status()
+}
+
+func (s *SshFetcher) status() ConnStatus {
+	return ConnStatus{
+		Reconnecting: s.conn.lost,
+		Attempts:     s.conn.attempts,
+		NextAttempt:  s.conn.nextAttempt,
+		LastError:    s.conn.lastErr,
+		LastUpdate:   s.conn.lastUpdate,
+	}
+}
+
+// Connect dials the host with Redial and probes it. A host that cannot be
+// reached is left to reconnect with backoff from GetAllStats.
+func (s *SshFetcher) Connect(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transport, err := s.Redial(ctx)
+	if err == nil {
+		s.Transport = transport
+		err = s.Probe(ctx)
+	}
+	if err != nil {
+		var connErr *ConnectionError
+		if s.Transport == nil || errors.As(err, &connErr) {
+			s.connectionLost(err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *SshFetcher) connectionLost(err error) {
+	logger.Warn("Will reconnect after error: %v", err)
+	if s.Transport != nil {
+		s.Transport.Close()
+	}
+	s.conn = connState{
+		lost:        true,
+		backoff:     minBackoff,
+		nextAttempt: time.Now(),
+		lastErr:     err,
+		lastUpdate:  s.conn.lastUpdate,
+	}
+}
+
+// reconnect redials once the backoff has passed, doubling it on failure
+func (s *SshFetcher) reconnect(ctx context.Context) error {
+	if s.Redial == nil {
+		return s.conn.lastErr
+	}
+	if time.Now().Before(s.conn.nextAttempt) {
+		return errWaitingToReconnect
+	}
+
+	s.conn.attempts++
+	logger.Info("Reconnecting (attempt %d)", s.conn.attempts)
+	transport, err := s.Redial(ctx)
+	if err == nil {
+		// Make sure the new link actually carries commands
+		if _, err = transport.RunCommand(ctx, "true"); err != nil {
+			transport.Close()
+		}
+	}
+	if err != nil {
+		s.conn.lastErr = err
+		s.conn.nextAttempt = time.Now().Add(s.conn.backoff)
+		logger.Warn("Reconnect failed, retrying in %v: %v", s.conn.backoff, err)
+		s.conn.backoff *= 2
+		if s.conn.backoff > maxBackoff {
+			s.conn.backoff = maxBackoff
+		}
+		return err
+	}
+
+	logger.Info("Reconnected after %d attempt(s)", s.conn.attempts)
+	s.Transport = transport
+	if s.sudo != nil {
+		s.sudo.Transport = transport
+	}
+	s.conn = connState{lastUpdate: s.conn.lastUpdate}
+
+	// The host may have been rebuilt or upgraded while we were away
+	if err := s.Probe(ctx); err != nil {
+		logger.Warn("Probe after reconnect failed, keeping previous capabilities: %v", err)
+	}
+	return nil
+}
@@ -2,72 +2,250 @@ package stats
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/0x0BSoD/rtop/pkg/logger"
 	"golang.org/x/crypto/ssh"
-	"log"
+	"io"
 	"net"
-	"os"
+	"strings"
+	"time"
 )
 
-func SshConnect(user, addr, keyPath string) (*ssh.Client, error) {
-	logger.Info("Establishing SSH connection to %s@%s", user, addr)
-	auths := make([]ssh.AuthMethod, 0)
+// Timeouts and keepalives for connections to monitored hosts
+var (
+	DialTimeout       = 15 * time.Second
+	CommandTimeout    = 15 * time.Second
+	KeepaliveInterval = 15 * time.Second // like ServerAliveInterval, 0 disables
+	KeepaliveCountMax = 3                // like ServerAliveCountMax
+)
+
+// SshDialer connects to a host with the built-in client. Keys are loaded on
+// the first dial and a typed password is remembered, so reconnecting never
+// prompts while the TUI owns the terminal.
+type SshDialer struct {
+	User     string
+	Addr     string
+	KeyPath  string
+	CertPath string
+
+	auths    []ssh.AuthMethod
+	password string
+}
+
+func NewSshDialer(user, addr, keyPath, certPath string) *SshDialer {
+	return &SshDialer{
+		User:     user,
+		Addr:     addr,
+		KeyPath:  keyPath,
+		CertPath: certPath,
+	}
+}
+
+func SshConnect(ctx context.Context, user, addr, keyPath, certPath string) (*ssh.Client, error) {
+	return NewSshDialer(user, addr, keyPath, certPath).Dial(ctx)
+}
+
+func (d *SshDialer) Dial(ctx context.Context) (*ssh.Client, error) {
+	logger.Info("Establishing SSH connection to %s@%s", d.User, d.Addr)
+	if DialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DialTimeout)
+		defer cancel()
+	}
+
+	// Certificates the agent keys may be paired with
+	agentCerts := certPaths(d.KeyPath, d.CertPath)
+	for _, key := range defaultKeyPaths() {
+		agentCerts = append(agentCerts, key+"-cert.pub")
+	}
 
 	// Try connecting via agent first
 	logger.Info("SSH Agent checking")
-	client, err := tryAgentConnect(user, addr)
+	client, err := tryAgentConnect(ctx, d.User, d.Addr, agentCerts)
 	if err != nil {
-		logger.Error("SSH connection with agent failed: %v", err)
-		return nil, fmt.Errorf("filed to use agent: %w", err)
+		var netErr net.Error
+		if errors.As(err, &netErr) || ctx.Err() != nil {
+			logger.Error("SSH connection failed: %v", err)
+			return nil, err
+		}
+		logger.Warn("SSH connection with agent failed, trying other methods: %v", err)
 	}
 	if client != nil {
 		logger.Info("SSH connection with agent established successfully")
+		keepalive(client)
 		return client, nil
 	}
 
 	// If that failed try with the key and password methods
-	if len(keyPath) > 0 {
-		auths = addKeyAuth(auths, keyPath) // User-specified key
-	} else {
-		auths = addDefaultKeys(auths) // Check ~/.ssh/id_* files
+	if d.auths == nil {
+		var auths []ssh.AuthMethod
+		if len(d.KeyPath) > 0 {
+			if auths, err = addKeyAuth(auths, d.KeyPath, d.CertPath); err != nil { // User-specified key
+				return nil, err
+			}
+		} else {
+			auths = addDefaultKeys(auths) // Check ~/.ssh/id_* files
+		}
+		d.auths = addPasswordAuth(d.User, d.Addr, auths, &d.password)
 	}
-	auths = addPasswordAuth(user, addr, auths)
 
 	config := &ssh.ClientConfig{
-		User: user,
-		Auth: auths,
-		HostKeyCallback: func(string, net.Addr, ssh.PublicKey) error {
-			return nil
-		},
+		User:            d.User,
+		Auth:            d.auths,
+		HostKeyCallback: hostKeyCallback(),
 	}
 
-	client, err = ssh.Dial("tcp", addr, config)
+	client, err = dialContext(ctx, d.Addr, config)
 	if err != nil {
-		log.Print(err)
-		os.Exit(1)
+		logger.Error("SSH connection failed: %v", err)
+		return nil, fmt.Errorf("failed to connect to %s: %w", d.Addr, err)
 	}
 
 	logger.Info("SSH connection established successfully")
+	keepalive(client)
 	return client, nil
 }
 
-func runCommand(client *ssh.Client, command string) (string, error) {
+// dialContext is ssh.Dial bounded by the context, handshake included
+func dialContext(ctx context.Context, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// keepalive sends keepalive@openssh.com requests every KeepaliveInterval and
+// closes the client once KeepaliveCountMax of them go unanswered, so a dead
+// link fails fast instead of hanging the next command
+func keepalive(client *ssh.Client) {
+	if KeepaliveInterval <= 0 {
+		return
+	}
+	interval, countMax := KeepaliveInterval, KeepaliveCountMax
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		missed := 0
+		for range ticker.C {
+			reply := make(chan error, 1)
+			go func() {
+				// Any reply, even a refusal, proves the server is alive
+				_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+				reply <- err
+			}()
+
+			select {
+			case err := <-reply:
+				if err != nil {
+					logger.Debug("Keepalive stopped: %v", err)
+					return
+				}
+				missed = 0
+			case <-time.After(interval):
+				missed++
+				logger.Warn("No keepalive reply from %s (%d/%d)", client.RemoteAddr(), missed, countMax)
+				if missed >= countMax {
+					logger.Error("Connection to %s timed out", client.RemoteAddr())
+					client.Close()
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Transport runs commands on the monitored host
+type Transport interface {
+	RunCommand(ctx context.Context, command string) (string, error)
+	// RunCommandInput is RunCommand with stdin fed from the reader, used to
+	// hand secrets to remote commands without putting them on argv
+	RunCommandInput(ctx context.Context, command string, stdin io.Reader) (string, error)
+	Close() error
+}
+
+// sshTransport runs commands over a golang.org/x/crypto/ssh connection
+type sshTransport struct {
+	client *ssh.Client
+}
+
+func NewSshTransport(client *ssh.Client) Transport {
+	return &sshTransport{client: client}
+}
+
+func (t *sshTransport) Close() error {
+	return t.client.Close()
+}
+
+func (t *sshTransport) RunCommand(ctx context.Context, command string) (string, error) {
+	return t.RunCommandInput(ctx, command, nil)
+}
+
+func (t *sshTransport) RunCommandInput(ctx context.Context, command string, stdin io.Reader) (string, error) {
+	if CommandTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, CommandTimeout)
+		defer cancel()
+	}
+
 	logger.Debug("Creating new SSH session")
-	session, err := client.NewSession()
+	session, err := t.client.NewSession()
 	if err != nil {
 		logger.Error("Failed to create SSH session: %v", err)
-		return "", fmt.Errorf("failed to create SSH session: %w", err)
+		return "", &ConnectionError{fmt.Errorf("failed to create SSH session: %w", err)}
 	}
 	defer session.Close()
 	logger.Debug("SSH session created successfully")
 
 	logger.Debug("Executing command: %s", command)
-	var buf bytes.Buffer
+	var buf, errBuf bytes.Buffer
+	session.Stdin = stdin
 	session.Stdout = &buf
-	if err := session.Run(command); err != nil {
-		logger.Error("Command execution failed: %s - %v", command, err)
-		return "", fmt.Errorf("failed to run command '%s': %w", command, err)
+	session.Stderr = &errBuf
+	if err := session.Start(command); err != nil {
+		logger.Error("Failed to start command: %s - %v", command, err)
+		return "", &ConnectionError{fmt.Errorf("failed to start command '%s': %w", command, err)}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		session.Signal(ssh.SIGKILL)
+		logger.Error("Command timed out: %s", command)
+		return "", fmt.Errorf("command '%s' did not finish: %w", command, ctx.Err())
+	}
+
+	if err != nil {
+		logger.Error("Command execution failed: %s - %v: %s", command, err, strings.TrimSpace(errBuf.String()))
+		cmdErr := &CommandError{Command: command, Status: -1, Stderr: errBuf.String(), Err: err}
+		// No exit status means the connection died under the command
+		var missing *ssh.ExitMissingError
+		if errors.As(err, &missing) {
+			return "", &ConnectionError{cmdErr}
+		}
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) {
+			cmdErr.Status = exitErr.ExitStatus()
+		}
+		return "", cmdErr
 	}
 
 	output := buf.String()
@@ -27,12 +27,13 @@ package stats
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
-	"golang.org/x/crypto/ssh"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/0x0BSoD/rtop/pkg/logger"
@@ -46,6 +47,7 @@ type Cgroup struct {
 	MemoryUsageLimit   int
 	IoReadBytes        int
 	IoWriteBytes       int
+	OomKills           int // processes killed for running out of memory
 	Open               bool
 	Childs             []*Cgroup
 	Parent             *Cgroup
@@ -59,10 +61,12 @@ type FSInfo struct {
 }
 
 type NetIntfInfo struct {
-	IPv4 string
-	IPv6 string
-	Rx   uint64
-	Tx   uint64
+	IPv4   string
+	IPv6   string
+	Rx     uint64
+	Tx     uint64
+	RxRate float64 // bytes per second since the previous round
+	TxRate float64
 }
 
 type cpuRaw struct {
@@ -107,86 +111,161 @@ type Stats struct {
 	FSInfos      []FSInfo
 	NetIntf      map[string]NetIntfInfo
 	CPU          CPUInfo // or []CPUInfo to get all the cpu-core's stats?
+	Cores        int
 	Cgroups      []*Cgroup
+	Errors       map[string]*CollectorError // by collector name, from the last round
+
+	preCPU     cpuRaw    // the CPU stats that were fetched last time round
+	netSampled time.Time // when the interface counters were last read
+	noStatfs   bool      // stat -f is missing, filesystems come from df
+}
+
+// MemUsed is the memory neither free nor holding buffers or the page cache
+func (st *Stats) MemUsed() uint64 {
+	available := st.MemFree + st.MemBuffers + st.MemCached
+	if available > st.MemTotal {
+		return 0
+	}
+	return st.MemTotal - available
 }
 
 type SshFetcher struct {
-	Client *ssh.Client
-	Logger *logger.Logger
-	Stats  *Stats
+	Name      string // the host as given by the user
+	Transport Transport
+	Logger    *logger.Logger
+	Stats     *Stats
+	// Degraded maps privileged collectors that had to run without sudo to
+	// the reason sudo was unavailable
+	Degraded map[string]string
+	// Redial replaces a lost transport, nil disables reconnecting
+	Redial func(ctx context.Context) (Transport, error)
+	// Caps is set by Probe
+	Caps *Capabilities
+	// OnCollect, when set, is called after every round of GetAllStats
+	OnCollect func(s *SshFetcher)
+	// Exclude names collectors not to run at all, see CollectorNames
+	Exclude map[string]bool
+
+	disabled map[string]string // collectors the probe ruled out, with why
+	sudo     *sudoTransport
+	mu       sync.Mutex
+	conn     connState
 }
 
-func NewSshFetcher(client *ssh.Client) *SshFetcher {
+func NewSshFetcher(transport Transport) *SshFetcher {
 	return &SshFetcher{
-		Client: client,
-		Stats:  &Stats{},
+		Transport: transport,
+		Stats:     &Stats{},
 	}
 }
 
-// ValidateOS - rtop only support for Linux system
-func (s *SshFetcher) ValidateOS() {
-	logger.Debug("Validating remote OS type")
-	ostype, err := runCommand(s.Client, "uname")
-	if err != nil {
-		logger.Fatal("Failed to get OS type: %v", err)
-		os.Exit(1)
-	}
-	//remove newline character
-	ostype = strings.Trim(ostype, "\n")
+// collector fills part of Stats from one or more remote commands
+type collector struct {
+	name       string
+	what       string
+	privileged bool          // run through sudo when it is enabled
+	requires   []requirement // checked against the probe, see Probe
+	fetch      func(ctx context.Context, client Transport, stats *Stats) error
+}
 
-	logger.Info("Remote OS detected: %s", ostype)
-	if !strings.EqualFold(ostype, "Linux") {
-		logger.Fatal("rtop not supported for %s system", ostype)
-		os.Exit(1)
+var collectors = []collector{
+	{name: "hostname", what: "hostname", fetch: getHostname,
+		requires: []requirement{needReadable("/proc/sys/kernel/hostname")}},
+	{name: "uptime", what: "uptime", fetch: getUptime,
+		requires: []requirement{needReadable("/proc/uptime")}},
+	{name: "load", what: "load average", fetch: getLoad,
+		requires: []requirement{needReadable("/proc/loadavg")}},
+	{name: "memory", what: "Mem metrics", fetch: getMemInfo,
+		requires: []requirement{needReadable("/proc/meminfo")}},
+	{name: "filesystems", what: "FS metrics", fetch: getFSInfo,
+		requires: []requirement{anyOf(needBinary("stat"), needBinary("df"))}},
+	{name: "interfaces", what: "interfaces", fetch: getInterfaces,
+		requires: []requirement{anyOf(needBinary("ip"), needReadable("/proc/net/route"))}},
+	{name: "interface-info", what: "interface info", fetch: getInterfaceInfo,
+		requires: []requirement{needReadable("/proc/net/dev")}},
+	{name: "cpu", what: "cpu metrics", fetch: getCPU,
+		requires: []requirement{needReadable("/proc/stat")}},
+	{name: "cgroups", what: "cgroups", privileged: true, fetch: getCgroups,
+		requires: []requirement{needCgroupV2, needBinary("find")}},
+}
+
+// CollectorNames lists every collector, in the order they run
+func CollectorNames() []string {
+	names := make([]string, len(collectors))
+	for i, c := range collectors {
+		names[i] = c.name
 	}
-	logger.Debug("OS validation successful")
+	return names
 }
 
-func (s *SshFetcher) GetAllStats() []error {
-	var errors []error
+// GetAllStats runs every collector. While the connection is down it only
+// attempts to reconnect, leaving the last collected Stats in place.
+func (s *SshFetcher) GetAllStats(ctx context.Context) []error {
+	errs := s.collect(ctx)
+	if s.OnCollect != nil {
+		s.OnCollect(s)
+	}
+	return errs
+}
 
-	if err := getHostname(s.Client, s.Stats); err != nil {
-		logger.Fatal("Failed to get hostname: %v", err)
-		errors = append(errors, err)
+func (s *SshFetcher) collect(ctx context.Context) []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
+	if s.conn.lost {
+		if err := s.reconnect(ctx); err != nil {
+			return []error{err}
+		}
 	}
-	if err := getUptime(s.Client, s.Stats); err != nil {
-		logger.Fatal("Failed to get uptime: %v", err)
-		errors = append(errors, err)
-	}
-	if err := getLoad(s.Client, s.Stats); err != nil {
-		logger.Fatal("Failed to get load average: %v", err)
-		errors = append(errors, err)
-	}
-	if err := getMemInfo(s.Client, s.Stats); err != nil {
-		logger.Fatal("Failed to get Mem metrics: %v", err)
-		errors = append(errors, err)
-	}
-	if err := getFSInfo(s.Client, s.Stats); err != nil {
-		logger.Fatal("Failed to get FS metrics: %v", err)
-		errors = append(errors, err)
-	}
-	if err := getInterfaces(s.Client, s.Stats); err != nil {
-		logger.Fatal("Failed to get interfaces: %v", err)
-		errors = append(errors, err)
-	}
-	if err := getInterfaceInfo(s.Client, s.Stats); err != nil {
-		logger.Fatal("Failed to get interface info: %v", err)
-		errors = append(errors, err)
-	}
-	if err := getCPU(s.Client, s.Stats); err != nil {
-		logger.Fatal("Failed to get cpu metrics: %v", err)
-		errors = append(errors, err)
-	}
-	if err := getCgroups(s.Client, s.Stats); err != nil {
-		logger.Fatal("Failed to get vgroups: %v", err)
-		errors = append(errors, err)
+
+	var errs []error
+	roundErrors := make(map[string]*CollectorError)
+
+	for _, c := range collectors {
+		if s.Exclude[c.name] {
+			continue
+		}
+		if reason, off := s.disabled[c.name]; off {
+			roundErrors[c.name] = &CollectorError{Collector: c.name, Err: fmt.Errorf("%w: %s", ErrUnsupported, reason)}
+			errs = append(errs, roundErrors[c.name])
+			continue
+		}
+
+		var err error
+		if c.privileged && s.sudo != nil {
+			err = s.fetchPrivileged(ctx, c)
+		} else {
+			err = c.fetch(ctx, s.Transport, s.Stats)
+		}
+		if err == nil {
+			continue
+		}
+
+		collectorErr := &CollectorError{Collector: c.name, Err: err}
+		roundErrors[c.name] = collectorErr
+		errs = append(errs, collectorErr)
+
+		var connErr *ConnectionError
+		if errors.As(err, &connErr) {
+			s.Stats.Errors = roundErrors
+			if s.Redial == nil {
+				// Nothing to replace the transport with, so keep it for the
+				// next round rather than closing what may not be ours
+				logger.Error("Failed to get %s: %v", c.what, err)
+				return errs
+			}
+			s.connectionLost(err)
+			return errs
+		}
+		logger.Error("Failed to get %s: %v", c.what, err)
 	}
-	return errors
+	s.Stats.Errors = roundErrors
+	s.conn.lastUpdate = time.Now()
+	return errs
 }
 
-func getUptime(client *ssh.Client, stats *Stats) (err error) {
-	uptime, err := runCommand(client, "/bin/cat /proc/uptime")
+func getUptime(ctx context.Context, client Transport, stats *Stats) (err error) {
+	uptime, err := client.RunCommand(ctx, readCommand("/proc/uptime"))
 	if err != nil {
 		return
 	}
@@ -204,8 +283,10 @@ func getUptime(client *ssh.Client, stats *Stats) (err error) {
 	return
 }
 
-func getHostname(client *ssh.Client, stats *Stats) (err error) {
-	hostname, err := runCommand(client, "/bin/hostname -f")
+func getHostname(ctx context.Context, client Transport, stats *Stats) (err error) {
+	// BusyBox hostname -f fails without a resolvable name, the kernel's is
+	// good enough then
+	hostname, err := client.RunCommand(ctx, "hostname -f 2>/dev/null || "+readCommand("/proc/sys/kernel/hostname"))
 	if err != nil {
 		return
 	}
@@ -214,8 +295,8 @@ func getHostname(client *ssh.Client, stats *Stats) (err error) {
 	return
 }
 
-func getLoad(client *ssh.Client, stats *Stats) (err error) {
-	line, err := runCommand(client, "/bin/cat /proc/loadavg")
+func getLoad(ctx context.Context, client Transport, stats *Stats) (err error) {
+	line, err := client.RunCommand(ctx, readCommand("/proc/loadavg"))
 	if err != nil {
 		return
 	}
@@ -236,8 +317,8 @@ func getLoad(client *ssh.Client, stats *Stats) (err error) {
 	return
 }
 
-func getMemInfo(client *ssh.Client, stats *Stats) (err error) {
-	lines, err := runCommand(client, "/bin/cat /proc/meminfo")
+func getMemInfo(ctx context.Context, client Transport, stats *Stats) (err error) {
+	lines, err := client.RunCommand(ctx, readCommand("/proc/meminfo"))
 	if err != nil {
 		return
 	}
@@ -272,50 +353,66 @@ func getMemInfo(client *ssh.Client, stats *Stats) (err error) {
 	return
 }
 
-func getFSInfo(client *ssh.Client, stats *Stats) (err error) {
-	lines, err := runCommand(client, "/bin/df -PB1")
-	if err != nil {
-		return
+func getFSInfo(ctx context.Context, client Transport, stats *Stats) error {
+	var infos []FSInfo
+	err := errNoStatfs
+	if !stats.noStatfs {
+		infos, err = statfsInfo(ctx, client)
+		stats.noStatfs = commandNotFound(err)
 	}
+	if err != nil {
+		var connErr *ConnectionError
+		if errors.As(err, &connErr) {
+			return err
+		}
+		logger.Debug("Reading mounts directly failed, falling back to df: %v", err)
 
-	scanner := bufio.NewScanner(strings.NewReader(lines))
-	flag := 0
-	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.Fields(line)
-		n := len(parts)
-		dev := n > 0 && strings.Index(parts[0], "/dev/") == 0
-		if n == 1 && dev {
-			flag = 1
-		} else if (n == 5 && flag == 1) || (n == 6 && dev) {
-			i := flag
-			flag = 0
-			used, err := strconv.ParseUint(parts[2-i], 10, 64)
-			if err != nil {
-				continue
-			}
-			free, err := strconv.ParseUint(parts[3-i], 10, 64)
-			if err != nil {
-				continue
-			}
-			stats.FSInfos = append(stats.FSInfos, FSInfo{
-				parts[0], parts[5-i], used, free,
-			})
+		// -Pk is what POSIX and BusyBox agree on, unlike GNU's -B1
+		var lines string
+		lines, err = client.RunCommand(ctx, "df -Pk")
+		if err != nil {
+			return err
 		}
+		infos = parseDf(lines, 1024)
 	}
 
-	return
+	stats.FSInfos = infos
+	return nil
 }
 
-func getInterfaces(client *ssh.Client, stats *Stats) (err error) {
-	var lines string
-	lines, err = runCommand(client, "/bin/ip -o addr")
+var errNoStatfs = errors.New("stat -f not available")
+
+// statfsInfo lists block device mounts from /proc/self/mountinfo and sizes
+// them with stat -f
+func statfsInfo(ctx context.Context, client Transport) ([]FSInfo, error) {
+	lines, err := client.RunCommand(ctx, readCommand("/proc/self/mountinfo"))
 	if err != nil {
-		// try /sbin/ip
-		lines, err = runCommand(client, "/sbin/ip -o addr")
-		if err != nil {
-			return
-		}
+		return nil, err
+	}
+	mounts := parseMountinfo(lines)
+	if len(mounts) == 0 {
+		return nil, nil
+	}
+
+	paths := make([]string, len(mounts))
+	for i, m := range mounts {
+		paths[i] = m.MountPoint
+	}
+	lines, err = client.RunCommand(ctx, statfsCommand(paths))
+	if err != nil {
+		return nil, err
+	}
+	return parseStatfs(lines, mounts), nil
+}
+
+func getInterfaces(ctx context.Context, client Transport, stats *Stats) (err error) {
+	// ip often lives in an sbin directory missing from a user's PATH
+	lines, err := client.RunCommand(ctx, "PATH=$PATH:/sbin:/usr/sbin; ip -o addr")
+	if commandNotFound(err) {
+		return getInterfacesProc(ctx, client, stats)
+	}
+	if err != nil {
+		return
 	}
 
 	if stats.NetIntf == nil {
@@ -351,8 +448,39 @@ func getInterfaces(client *ssh.Client, stats *Stats) (err error) {
 	return
 }
 
-func getInterfaceInfo(client *ssh.Client, stats *Stats) (err error) {
-	lines, err := runCommand(client, "/bin/cat /proc/net/dev")
+// getInterfacesProc is getInterfaces for hosts without ip, built from
+// /sys/class/net, /proc/net/if_inet6 and the IPv4 routing tables
+func getInterfacesProc(ctx context.Context, client Transport, stats *Stats) error {
+	out, err := client.RunCommand(ctx,
+		`for i in /sys/class/net/*; do echo "${i##*/}"; done; `+
+			sectionsCommand("/proc/net/if_inet6", "/proc/net/fib_trie", "/proc/net/route"))
+	if err != nil {
+		return err
+	}
+	names, rest, _ := strings.Cut(out, "==> ")
+	sections := splitSections("==> " + rest)
+
+	ipv4 := ipv4Addrs(parseFibTrie(sections["/proc/net/fib_trie"]), parseRoutes(sections["/proc/net/route"]))
+	ipv6 := parseIfInet6(sections["/proc/net/if_inet6"])
+
+	if stats.NetIntf == nil {
+		stats.NetIntf = make(map[string]NetIntfInfo)
+	}
+	for _, name := range strings.Fields(names) {
+		// Like ip -o addr, leave out interfaces without addresses
+		if len(ipv4[name]) == 0 && len(ipv6[name]) == 0 {
+			continue
+		}
+		info := stats.NetIntf[name]
+		info.IPv4 = ipv4[name]
+		info.IPv6 = ipv6[name]
+		stats.NetIntf[name] = info
+	}
+	return nil
+}
+
+func getInterfaceInfo(ctx context.Context, client Transport, stats *Stats) (err error) {
+	lines, err := client.RunCommand(ctx, readCommand("/proc/net/dev"))
 	if err != nil {
 		return
 	}
@@ -361,6 +489,13 @@ func getInterfaceInfo(client *ssh.Client, stats *Stats) (err error) {
 		return
 	} // should have been here already
 
+	now := time.Now()
+	elapsed := now.Sub(stats.netSampled).Seconds()
+	if stats.netSampled.IsZero() {
+		elapsed = 0
+	}
+	stats.netSampled = now
+
 	scanner := bufio.NewScanner(strings.NewReader(lines))
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -377,6 +512,11 @@ func getInterfaceInfo(client *ssh.Client, stats *Stats) (err error) {
 				if err != nil {
 					continue
 				}
+				// Counters reset when an interface is recreated
+				if elapsed > 0 && rx >= info.Rx && tx >= info.Tx && info.Rx+info.Tx > 0 {
+					info.RxRate = float64(rx-info.Rx) / elapsed
+					info.TxRate = float64(tx-info.Tx) / elapsed
+				}
 				info.Rx = rx
 				info.Tx = tx
 				stats.NetIntf[intf] = info
@@ -419,11 +559,8 @@ func parseCPUFields(fields []string, stat *cpuRaw) {
 	}
 }
 
-// the CPU stats that were fetched last time round
-var preCPU cpuRaw
-
-func getCPU(client *ssh.Client, stats *Stats) error {
-	lines, err := runCommand(client, "/bin/cat /proc/stat")
+func getCPU(ctx context.Context, client Transport, stats *Stats) error {
+	lines, err := client.RunCommand(ctx, readCommand("/proc/stat"))
 	if err != nil {
 		return err
 	}
@@ -433,15 +570,19 @@ func getCPU(client *ssh.Client, stats *Stats) error {
 		total  float32
 	)
 
+	cores := 0
 	scanner := bufio.NewScanner(strings.NewReader(lines))
 	for scanner.Scan() {
 		line := scanner.Text()
 		fields := strings.Fields(line)
 		if len(fields) > 0 && fields[0] == "cpu" { // changing here if want to get every cpu-core's stats
 			parseCPUFields(fields, &nowCPU)
-			break
+		} else if len(fields) > 0 && strings.HasPrefix(fields[0], "cpu") {
+			cores++
 		}
 	}
+	stats.Cores = cores
+	preCPU := stats.preCPU
 	if preCPU.Total == 0 { // having no pre raw cpu data
 		goto END
 	}
@@ -454,22 +595,26 @@ func getCPU(client *ssh.Client, stats *Stats) error {
 	stats.CPU.Iowait = float32(nowCPU.Iowait-preCPU.Iowait) / total * 100
 	stats.CPU.Irq = float32(nowCPU.Irq-preCPU.Irq) / total * 100
 	stats.CPU.SoftIrq = float32(nowCPU.SoftIrq-preCPU.SoftIrq) / total * 100
+	stats.CPU.Steal = float32(nowCPU.Steal-preCPU.Steal) / total * 100
 	stats.CPU.Guest = float32(nowCPU.Guest-preCPU.Guest) / total * 100
 END:
-	preCPU = nowCPU
+	stats.preCPU = nowCPU
 	return err
 }
 
-func findChildCgroups(parentPath string, client *ssh.Client) ([]string, error) {
-	data, err := runCommand(client, fmt.Sprintf("find %s -mindepth 1 -maxdepth 1 -type d | grep slice$", parentPath))
+// findChildCgroups lists the slices below a slice, which are walked in turn,
+// and the services, which are leaves
+func findChildCgroups(ctx context.Context, parentPath string, client Transport) ([]string, error) {
+	data, err := client.RunCommand(ctx, fmt.Sprintf(
+		"find %s -mindepth 1 -maxdepth 1 -type d \\( -name '*.slice' -o -name '*.service' \\)", shellQuote(parentPath)))
 	if err != nil {
 		return nil, err
 	}
 
-	return strings.Split(strings.TrimSpace(data), "\n"), nil
+	return strings.Fields(data), nil
 }
 
-func getCgroupsData(entry string, parent *Cgroup, stats *Stats, client *ssh.Client) error {
+func getCgroupsData(ctx context.Context, entry string, parent *Cgroup, stats *Stats, client Transport) error {
 	cgroup := &Cgroup{
 		Version: "v2",
 		Path:    entry,
@@ -481,12 +626,20 @@ func getCgroupsData(entry string, parent *Cgroup, stats *Stats, client *ssh.Clie
 		parent.Childs = append(parent.Childs, cgroup)
 	}
 
-	data, err := runCommand(client, fmt.Sprintf("cat %s/cpu.stat", entry))
+	// One command for all the files, those of controllers not enabled for
+	// the cgroup come out empty
+	files := []string{"cpu.stat", "memory.current", "memory.max", "memory.events", "io.stat"}
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = entry + "/" + f
+	}
+	data, err := client.RunCommand(ctx, sectionsCommand(paths...)+"; exit 0")
 	if err != nil {
 		return err
 	}
+	sections := splitSections(data)
 
-	rawCpuStats := strings.Split(strings.TrimSpace(data), "\n")
+	rawCpuStats := strings.Split(strings.TrimSpace(sections[entry+"/cpu.stat"]), "\n")
 	cpuStat := make(map[string]float64, len(rawCpuStats))
 	for _, line := range rawCpuStats {
 		fields := strings.Fields(line)
@@ -499,23 +652,17 @@ func getCgroupsData(entry string, parent *Cgroup, stats *Stats, client *ssh.Clie
 	}
 	cgroup.CpuUsage = cpuStat["usage_usec"] / 1000000.00
 
-	data, err = runCommand(client, fmt.Sprintf("cat %s/memory.current", entry))
-	if err != nil {
-		return err
-	}
-	cgroup.MemoryUsageCurrent, _ = strconv.Atoi(strings.TrimSpace(data))
+	cgroup.MemoryUsageCurrent, _ = strconv.Atoi(strings.TrimSpace(sections[entry+"/memory.current"]))
+	cgroup.MemoryUsageLimit, _ = strconv.Atoi(strings.TrimSpace(sections[entry+"/memory.max"]))
 
-	data, err = runCommand(client, fmt.Sprintf("cat %s/memory.max", entry))
-	if err != nil {
-		return err
+	// Only kernels from 4.13 count OOM kills
+	for _, line := range strings.Split(sections[entry+"/memory.events"], "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "oom_kill" {
+			cgroup.OomKills, _ = strconv.Atoi(fields[1])
+		}
 	}
-	cgroup.MemoryUsageLimit, _ = strconv.Atoi(strings.TrimSpace(data))
 
-	data, err = runCommand(client, fmt.Sprintf("cat %s/io.stat", entry))
-	if err != nil {
-		return err
-	}
-	rawIoStats := strings.Split(strings.TrimSpace(data), "\n")
+	rawIoStats := strings.Split(strings.TrimSpace(sections[entry+"/io.stat"]), "\n")
 
 	ioStat := make(map[string]map[string]int, len(rawIoStats))
 	var mapKey string
@@ -548,14 +695,17 @@ func getCgroupsData(entry string, parent *Cgroup, stats *Stats, client *ssh.Clie
 	cgroup.IoReadBytes = ioRead
 	cgroup.IoWriteBytes = ioWrite
 
-	childDirs, err := findChildCgroups(entry, client)
-	if err != nil {
-		return err
+	var childDirs []string
+	if !strings.HasSuffix(entry, ".service") {
+		childDirs, err = findChildCgroups(ctx, entry, client)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Recursively process each child
 	for _, childDir := range childDirs {
-		err = getCgroupsData(childDir, cgroup, stats, client)
+		err = getCgroupsData(ctx, childDir, cgroup, stats, client)
 		if err != nil {
 			// Handle error or continue with next child
 			continue
@@ -570,11 +720,11 @@ func getCgroupsData(entry string, parent *Cgroup, stats *Stats, client *ssh.Clie
 	return nil
 }
 
-func getCgroups(client *ssh.Client, stats *Stats) error {
+func getCgroups(ctx context.Context, client Transport, stats *Stats) error {
 	cgroupPath := "/sys/fs/cgroup"
 
 	//Check if cgroups v2 is being used
-	isV2, err := runCommand(client,
+	isV2, err := client.RunCommand(ctx,
 		fmt.Sprintf("if [ -f %s ];then echo -n 'True'; fi", filepath.Join(cgroupPath, "cgroup.controllers")))
 	if err != nil {
 		return err
@@ -586,7 +736,7 @@ func getCgroups(client *ssh.Client, stats *Stats) error {
 	}
 
 	// Get all top-level cgroups
-	entries, err := runCommand(client, fmt.Sprintf("find %s -maxdepth 1 -type d | grep \"^%s/.*\\.slice$\"", cgroupPath, cgroupPath))
+	entries, err := client.RunCommand(ctx, fmt.Sprintf("find %s -maxdepth 1 -type d | grep \"^%s/.*\\.slice$\"", cgroupPath, cgroupPath))
 	if err != nil {
 		return err
 	}
@@ -596,7 +746,7 @@ func getCgroups(client *ssh.Client, stats *Stats) error {
 	stats.Cgroups = nil
 
 	for _, entry := range cgroups {
-		err := getCgroupsData(entry, nil, stats, client)
+		err := getCgroupsData(ctx, entry, nil, stats, client)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,141 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeTransport answers each command with the output of the first of its
+// replies whose key the command contains
+type fakeTransport struct {
+	replies []reply
+}
+
+type reply struct {
+	key string
+	out string
+	err error
+}
+
+func (t *fakeTransport) RunCommand(ctx context.Context, command string) (string, error) {
+	for _, r := range t.replies {
+		if strings.Contains(command, r.key) {
+			return r.out, r.err
+		}
+	}
+	return "", fmt.Errorf("unexpected command %q", command)
+}
+
+func (t *fakeTransport) RunCommandInput(ctx context.Context, command string, stdin io.Reader) (string, error) {
+	return t.RunCommand(ctx, command)
+}
+
+func (t *fakeTransport) Close() error {
+	return nil
+}
+
+func TestGetCPU(t *testing.T) {
+	rounds := []string{
+		"cpu  100 10 50 800 20 5 5 10 0 0\ncpu0 50 5 25 400 10 2 3 5 0 0\ncpu1 50 5 25 400 10 3 2 5 0 0\nintr 1234\n",
+		"cpu  140 10 70 900 30 5 5 40 0 0\ncpu0 70 5 35 450 15 2 3 20 0 0\ncpu1 70 5 35 450 15 3 2 20 0 0\nintr 2345\n",
+	}
+	st := &Stats{}
+	for _, out := range rounds {
+		client := &fakeTransport{replies: []reply{{key: "/proc/stat", out: out}}}
+		if err := getCPU(context.Background(), client, st); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 200 jiffies went by between the rounds
+	want := CPUInfo{User: 20, System: 10, Idle: 50, Iowait: 5, Steal: 15}
+	got := st.CPU
+	for _, f := range []struct {
+		name      string
+		got, want float32
+	}{
+		{"User", got.User, want.User},
+		{"Nice", got.Nice, want.Nice},
+		{"System", got.System, want.System},
+		{"Idle", got.Idle, want.Idle},
+		{"Iowait", got.Iowait, want.Iowait},
+		{"Irq", got.Irq, want.Irq},
+		{"SoftIrq", got.SoftIrq, want.SoftIrq},
+		{"Steal", got.Steal, want.Steal},
+		{"Guest", got.Guest, want.Guest},
+	} {
+		if math.Abs(float64(f.got-f.want)) > 0.001 {
+			t.Errorf("CPU.%s = %v, want %v", f.name, f.got, f.want)
+		}
+	}
+	if st.Cores != 2 {
+		t.Errorf("Cores = %d, want 2", st.Cores)
+	}
+}
+
+func TestGetCgroupsData(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"system.slice/cpu.stat":                                       "usage_usec 3000000\nuser_usec 2000000\n",
+		"system.slice/memory.current":                                 "4096\n",
+		"system.slice/memory.max":                                     "max\n",
+		"system.slice/io.stat":                                        "8:0 rbytes=100 wbytes=200 rios=1 wios=2\n8:16 rbytes=1 wbytes=2\n",
+		"system.slice/nginx.service/cpu.stat":                         "usage_usec 1500000\n",
+		"system.slice/nginx.service/memory.current":                   "1024\n",
+		"system.slice/nginx.service/memory.max":                       "2048\n",
+		"system.slice/nginx.service/memory.events":                    "low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n",
+		"system.slice/nginx.service/workers/cpu.stat":                 "usage_usec 1\n",
+		"system.slice/system-getty.slice/cpu.stat":                    "usage_usec 500000\n",
+		"system.slice/system-getty.slice/memory.current":              "512\n",
+		"system.slice/system-getty.slice/memory.max":                  "max\n",
+		"system.slice/system-getty.slice/getty@tty1.service/cpu.stat": "usage_usec 250000\n",
+		"system.slice/session-1.scope/cpu.stat":                       "usage_usec 1\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st := &Stats{}
+	if err := getCgroupsData(context.Background(), filepath.Join(root, "system.slice"), nil, st, LocalTransport{}); err != nil {
+		t.Fatal(err)
+	}
+
+	type cgroup struct {
+		cpu                 float64
+		memory, limit, oom  int
+		read, write, childs int
+	}
+	got := make(map[string]cgroup)
+	var walk func([]*Cgroup)
+	walk = func(list []*Cgroup) {
+		for _, c := range list {
+			got[strings.TrimPrefix(c.Path, root+"/")] = cgroup{c.CpuUsage, c.MemoryUsageCurrent, c.MemoryUsageLimit,
+				c.OomKills, c.IoReadBytes, c.IoWriteBytes, len(c.Childs)}
+			walk(c.Childs)
+		}
+	}
+	walk(st.Cgroups)
+
+	want := map[string]cgroup{
+		"system.slice":                                       {cpu: 3, memory: 4096, read: 101, write: 202, childs: 2},
+		"system.slice/nginx.service":                         {cpu: 1.5, memory: 1024, limit: 2048, oom: 1},
+		"system.slice/system-getty.slice":                    {cpu: 0.5, memory: 512, childs: 1},
+		"system.slice/system-getty.slice/getty@tty1.service": {cpu: 0.25},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cgroups =\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -0,0 +1,108 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/0x0BSoD/rtop/pkg/logger"
+)
+
+// sudoTransport runs commands as root through sudo on the remote host. It
+// never prompts remotely: without a password it relies on sudo -n, with one
+// the password is fed on stdin and kept in memory for the session only.
+type sudoTransport struct {
+	Transport
+	password string
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (t *sudoTransport) RunCommand(ctx context.Context, command string) (string, error) {
+	return t.RunCommandInput(ctx, command, nil)
+}
+
+// prefix returns the sudo invocation and the stdin it needs
+func (t *sudoTransport) prefix(stdin io.Reader) (string, io.Reader) {
+	if len(t.password) == 0 {
+		return "sudo -n --", stdin
+	}
+	input := io.Reader(strings.NewReader(t.password + "\n"))
+	if stdin != nil {
+		input = io.MultiReader(input, stdin)
+	}
+	return "sudo -S -p '' --", input
+}
+
+func (t *sudoTransport) RunCommandInput(ctx context.Context, command string, stdin io.Reader) (string, error) {
+	sudo, input := t.prefix(stdin)
+	return t.Transport.RunCommandInput(ctx, sudo+" sh -c "+shellQuote(command), input)
+}
+
+// check reports why sudo cannot be used, or nil when it can
+func (t *sudoTransport) check(ctx context.Context) error {
+	sudo, input := t.prefix(nil)
+	out, err := t.Transport.RunCommandInput(ctx, "{ "+sudo+" true && echo rtop-sudo-ok; } 2>&1; true", input)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(out, "rtop-sudo-ok") {
+		return nil
+	}
+	reason := strings.Join(strings.Fields(strings.ReplaceAll(strings.TrimSpace(out), "\n", "; ")), " ")
+	if len(reason) == 0 {
+		reason = "sudo failed"
+	}
+	return errors.New(reason)
+}
+
+// EnableSudo makes privileged collectors run through sudo. An empty password
+// means sudo -n. When sudo does not work those collectors keep running
+// unprivileged and are listed in Degraded.
+func (s *SshFetcher) EnableSudo(password string) {
+	s.sudo = &sudoTransport{Transport: s.Transport, password: password}
+	if s.Transport == nil {
+		// Not connected yet, sudo gets checked when it first fails
+		return
+	}
+	if err := s.sudo.check(context.Background()); err != nil {
+		s.degrade(err)
+		return
+	}
+	logger.Info("sudo enabled for privileged collectors")
+}
+
+func (s *SshFetcher) degrade(reason error) {
+	logger.Warn("sudo unavailable, privileged collectors run unprivileged: %v", reason)
+	if s.Degraded == nil {
+		s.Degraded = make(map[string]string)
+	}
+	for _, c := range collectors {
+		if c.privileged {
+			s.Degraded[c.name] = reason.Error()
+			logger.Warn("Collector %s degraded: running without sudo", c.name)
+		}
+	}
+	s.sudo = nil
+}
+
+// fetchPrivileged runs a collector through sudo, falling back to running it
+// unprivileged if sudo stopped working since it was enabled
+func (s *SshFetcher) fetchPrivileged(ctx context.Context, c collector) error {
+	err := c.fetch(ctx, s.sudo, s.Stats)
+	var connErr *ConnectionError
+	if err == nil || errors.As(err, &connErr) {
+		return err
+	}
+	if checkErr := s.sudo.check(ctx); checkErr != nil {
+		if errors.As(checkErr, &connErr) {
+			return checkErr
+		}
+		s.degrade(checkErr)
+		return c.fetch(ctx, s.Transport, s.Stats)
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+package stats
+
+// HostView is what a fetcher knows about its host, copied so that it can be
+// read while the fetcher goes on collecting
+type HostView struct {
+	Stats      *Stats
+	Status     ConnStatus
+	Caps       *Capabilities
+	Collectors []CollectorState
+	Degraded   map[string]string
+}
+
+// View waits for a round in progress to end and copies the result
+func (s *SshFetcher) View() HostView {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	degraded := make(map[string]string, len(s.Degraded))
+	for name, reason := range s.Degraded {
+		degraded[name] = reason
+	}
+	return HostView{
+		Stats:      s.Stats.Copy(),
+		Status:     s.status(),
+		Caps:       s.Caps,
+		Collectors: s.CollectorStates(),
+		Degraded:   degraded,
+	}
+}
+
+// Copy returns a deep copy of the stats, cgroups included
+func (st *Stats) Copy() *Stats {
+	if st == nil {
+		return nil
+	}
+	c := *st
+	c.FSInfos = append([]FSInfo(nil), st.FSInfos...)
+	if st.NetIntf != nil {
+		c.NetIntf = make(map[string]NetIntfInfo, len(st.NetIntf))
+		for name, info := range st.NetIntf {
+			c.NetIntf[name] = info
+		}
+	}
+	if st.Errors != nil {
+		c.Errors = make(map[string]*CollectorError, len(st.Errors))
+		for name, err := range st.Errors {
+			c.Errors[name] = err
+		}
+	}
+	c.Cgroups = copyCgroups(st.Cgroups, nil)
+	return &c
+}
+
+func copyCgroups(cgroups []*Cgroup, parent *Cgroup) []*Cgroup {
+	if cgroups == nil {
+		return nil
+	}
+	copies := make([]*Cgroup, len(cgroups))
+	for i, cgroup := range cgroups {
+		c := *cgroup
+		c.Parent = parent
+		c.Childs = copyCgroups(cgroup.Childs, &c)
+		copies[i] = &c
+	}
+	return copies
+}
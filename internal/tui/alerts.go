@@ -0,0 +1,89 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/0x0BSoD/rtop/internal/alert"
+)
+
+// alerting lists the alerts firing on the host that belong to any of panels,
+// or all of them without panels
+func (m Model) alerting(panels ...string) []alert.Alert {
+	if m.Alerts == nil {
+		return nil
+	}
+	var alerts []alert.Alert
+	for _, a := range m.Alerts.Active(m.SshFetcher.Name) {
+		if len(panels) == 0 {
+			alerts = append(alerts, a)
+			continue
+		}
+		for _, panel := range panels {
+			if a.Rule.Panel() == panel {
+				alerts = append(alerts, a)
+				break
+			}
+		}
+	}
+	return alerts
+}
+
+// alertLines renders the alerts of panels, a line each
+func (m Model) alertLines(panels ...string) string {
+	var sb strings.Builder
+	for _, a := range m.alerting(panels...) {
+		sb.WriteString(warnStyle.Render(" ALERT "))
+		sb.WriteString(errorStyle.Render(fmt.Sprintf(" %s (%s) since %s", a.Rule.Text, formatValue(a.Value), a.Since.Format("15:04:05"))))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// formatValue shows a metric value without float noise
+func formatValue(v float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", v), "0"), ".")
+}
+
+func (m Model) viewAlerts() string {
+	var sb strings.Builder
+
+	sb.WriteString(titleStyle.Render(" Alerts "))
+	sb.WriteString("\n\n")
+
+	if m.Alerts == nil {
+		sb.WriteString("No alert rules loaded, see --alerts\n")
+		return sb.String()
+	}
+
+	active := m.alerting()
+	if len(active) == 0 {
+		sb.WriteString("Nothing firing\n")
+	}
+	for _, a := range active {
+		sb.WriteString(fmt.Sprintf("%s %s (%s) since %s\n",
+			warnStyle.Render(" FIRING "), a.Rule.Text, formatValue(a.Value), a.Since.Format("15:04:05")))
+	}
+
+	sb.WriteString("\n")
+	sb.WriteString(keywordStyle.Render("History"))
+	sb.WriteString("\n")
+	var history []alert.Event
+	for _, ev := range m.Alerts.History() {
+		if ev.Host == m.SshFetcher.Name {
+			history = append(history, ev)
+		}
+	}
+	if len(history) == 0 {
+		sb.WriteString("No alerts yet\n")
+	}
+	for _, ev := range history {
+		state := errorStyle.Render(fmt.Sprintf("%-8s", ev.State()))
+		if ev.Resolved {
+			state = labelStyle.Render(fmt.Sprintf("%-8s", ev.State()))
+		}
+		sb.WriteString(fmt.Sprintf("%s %s %s (%s)\n", ev.At.Format("2006-01-02 15:04:05"), state, ev.Rule.Text, formatValue(ev.Value)))
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,34 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (m Model) viewCapabilities() string {
+	var sb strings.Builder
+
+	sb.WriteString(titleStyle.Render(" Host capabilities "))
+	sb.WriteString("\n\n")
+
+	caps := m.caps
+	if caps == nil {
+		sb.WriteString("Host not probed yet\n")
+		return sb.String()
+	}
+	sb.WriteString(caps.Report())
+
+	sb.WriteString("\nCollectors:\n")
+	for _, state := range m.collectors {
+		if state.Enabled {
+			sb.WriteString(fmt.Sprintf("  %-16s %s\n", state.Name, "enabled"))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("  %-16s %s\n", state.Name, errorStyle.Render("disabled: "+state.Reason)))
+	}
+	for name, reason := range m.degraded {
+		sb.WriteString(fmt.Sprintf("  %-16s %s\n", name, warnStyle.Render("without sudo: "+reason)))
+	}
+
+	return groupStyle.Width(80).Render(sb.String())
+}
@@ -4,12 +4,13 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/0x0BSoD/rtop/internal/alert"
 	"github.com/0x0BSoD/rtop/internal/stats"
 )
 
 func (m Model) getCurrentLevelCgroup() []*stats.Cgroup {
 	if len(m.path) == 0 {
-		return m.SshFetcher.Stats.Cgroups
+		return m.stats.Cgroups
 	}
 
 	currentParent := m.path[len(m.path)-1]
@@ -28,6 +29,9 @@ func (m Model) viewCgroups() string {
 	var sb strings.Builder
 	cgroup := m.getCurrentLevelCgroup()
 
+	sb.WriteString(m.unavailable("cgroups"))
+	sb.WriteString(m.alertLines(alert.PanelCgroup))
+
 	// Show current path
 	if len(m.path) > 0 {
 		path := make([]string, len(m.path))
@@ -0,0 +1,341 @@
+package tui
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/0x0BSoD/rtop/internal/stats"
+)
+
+// Differences worth pointing out between two hosts
+const (
+	diffPoints  = 10.0 // percentage points of CPU, memory or disk
+	diffRatio   = 2.0  // for rates and cgroup usage
+	diffMinRate = 1024 // bytes per second, below that rates are noise
+)
+
+const (
+	compareLabelWidth = 22
+	compareValueWidth = 26
+)
+
+// Compare shows two hosts side by side and highlights where they differ
+type Compare struct {
+	UpdateInterval time.Duration
+	hosts          [2]Model
+	fetching       [2]bool
+	viewport       viewport.Model
+	width          int
+	height         int
+}
+
+func NewCompare(a, b *stats.SshFetcher, interval time.Duration) Compare {
+	c := Compare{UpdateInterval: interval}
+	for i, f := range []*stats.SshFetcher{a, b} {
+		c.hosts[i] = NewModel(f, interval)
+	}
+	return c
+}
+
+func (c Compare) Init() tea.Cmd {
+	tea.SetWindowTitle("rtop compare")
+	return tea.Tick(c.UpdateInterval, func(t time.Time) tea.Msg {
+		return tickMsg(t)
+	})
+}
+
+func (c Compare) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds []tea.Cmd
+
+	switch msg := msg.(type) {
+	case hostStatsMsg:
+		c.fetching[msg.index] = false
+		h, _ := c.hosts[msg.index].Update(msg.statsMsg)
+		c.hosts[msg.index] = h.(Model)
+	case tea.KeyMsg:
+		if key.Matches(msg, keys.Quit) {
+			return c, tea.Quit
+		}
+		var cmd tea.Cmd
+		c.viewport, cmd = c.viewport.Update(msg)
+		cmds = append(cmds, cmd)
+	case tea.WindowSizeMsg:
+		c.width = msg.Width
+		c.height = msg.Height
+		c.viewport.Width = msg.Width
+		c.viewport.Height = msg.Height - 1
+	case tickMsg:
+		for i, m := range c.hosts {
+			if !c.fetching[i] {
+				c.fetching[i] = true
+				cmds = append(cmds, fetchHostCmd(i, m.SshFetcher))
+			}
+		}
+		cmds = append(cmds,
+			tea.Tick(c.UpdateInterval, func(t time.Time) tea.Msg {
+				return tickMsg(t)
+			}),
+		)
+	}
+
+	c.viewport.SetContent(c.viewContent())
+	return c, tea.Batch(cmds...)
+}
+
+func (c Compare) View() string {
+	help := helpStyle.Render("↑/↓: Scroll  q: Quit")
+	return fmt.Sprintf("%s\n%s", c.viewport.View(), help)
+}
+
+// compareRow is a line of a section, highlighted when the values differ
+// significantly
+type compareRow struct {
+	label  string
+	values [2]string
+	differ bool
+}
+
+func (c Compare) viewContent() string {
+	var sb strings.Builder
+
+	var names [2]string
+	for i, m := range c.hosts {
+		names[i] = m.SshFetcher.Name
+		if h := m.stats.Hostname; len(h) > 0 && h != names[i] {
+			names[i] += " (" + h + ")"
+		}
+	}
+	sb.WriteString(renderCompareRow(compareRow{values: names}, titleStyle))
+	var status [2]string
+	for i, m := range c.hosts {
+		status[i] = "up " + formatDurationWithDays(m.stats.Uptime)
+		if m.status.Reconnecting {
+			status[i] = "RECONNECTING"
+		}
+	}
+	sb.WriteString(renderCompareRow(compareRow{label: "Status", values: status, differ: status[0][:2] != status[1][:2]}, lipgloss.NewStyle()))
+
+	a, b := c.hosts[0].stats, c.hosts[1].stats
+	sections := []struct {
+		title string
+		rows  []compareRow
+	}{
+		{"CPU", compareCPU(a, b)},
+		{"Memory", compareMemory(a, b)},
+		{"Filesystems", compareFilesystems(a, b)},
+		{"Interfaces", compareInterfaces(a, b)},
+		{"Cgroups", compareCgroups(a, b)},
+	}
+	for _, section := range sections {
+		sb.WriteString("\n" + keywordStyle.Render(section.title) + "\n")
+		if len(section.rows) == 0 {
+			sb.WriteString(helpStyle.Render("  no data") + "\n")
+		}
+		for _, row := range section.rows {
+			sb.WriteString(renderCompareRow(row, lipgloss.NewStyle()))
+		}
+	}
+	return sb.String()
+}
+
+func renderCompareRow(row compareRow, style lipgloss.Style) string {
+	if row.differ {
+		style = diffStyle
+	}
+	name := row.label
+	if max := compareLabelWidth - 2; len(name) > max {
+		name = "…" + name[len(name)-max+1:]
+	}
+	label := lipgloss.NewStyle().Width(compareLabelWidth).Render(labelStyle.Render(name))
+	cells := make([]string, 2)
+	for i, v := range row.values {
+		cells[i] = lipgloss.NewStyle().Width(compareValueWidth).Render(style.Render(v))
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, label, cells[0], cells[1]) + "\n"
+}
+
+func percentRow(label string, a, b float64) compareRow {
+	return compareRow{
+		label:  label,
+		values: [2]string{fmt.Sprintf("%5.1f%%", a), fmt.Sprintf("%5.1f%%", b)},
+		differ: math.Abs(a-b) >= diffPoints,
+	}
+}
+
+// ratioDiffers tells whether two quantities are apart by diffRatio, ignoring
+// those below floor
+func ratioDiffers(a, b, floor float64) bool {
+	lo, hi := math.Min(a, b), math.Max(a, b)
+	if hi < floor {
+		return false
+	}
+	return lo == 0 || hi/lo >= diffRatio
+}
+
+func compareCPU(a, b *stats.Stats) []compareRow {
+	return []compareRow{
+		percentRow("User", float64(a.CPU.User), float64(b.CPU.User)),
+		percentRow("System", float64(a.CPU.System), float64(b.CPU.System)),
+		percentRow("Nice", float64(a.CPU.Nice), float64(b.CPU.Nice)),
+		percentRow("Iowait", float64(a.CPU.Iowait), float64(b.CPU.Iowait)),
+		percentRow("Irq", float64(a.CPU.Irq), float64(b.CPU.Irq)),
+		percentRow("SoftIrq", float64(a.CPU.SoftIrq), float64(b.CPU.SoftIrq)),
+		percentRow("Steal", float64(a.CPU.Steal), float64(b.CPU.Steal)),
+		percentRow("Guest", float64(a.CPU.Guest), float64(b.CPU.Guest)),
+		percentRow("Total", cpuUsed(a)*100, cpuUsed(b)*100),
+		{label: "Load/Cores", values: [2]string{
+			fmt.Sprintf("%s/%d", a.Load1, a.Cores), fmt.Sprintf("%s/%d", b.Load1, b.Cores),
+		}},
+	}
+}
+
+// compareMemory shows the composition of memory as shares of the total, so
+// hosts of different sizes can be compared
+func compareMemory(a, b *stats.Stats) []compareRow {
+	share := func(st *stats.Stats, v uint64) float64 {
+		if st.MemTotal == 0 {
+			return 0
+		}
+		return float64(v) / float64(st.MemTotal) * 100
+	}
+	swap := func(st *stats.Stats) float64 {
+		if st.SwapTotal == 0 {
+			return 0
+		}
+		return float64(st.SwapTotal-st.SwapFree) / float64(st.SwapTotal) * 100
+	}
+	row := func(label string, va, vb uint64) compareRow {
+		r := percentRow(label, share(a, va), share(b, vb))
+		r.values[0] += " " + formatBytes(va)
+		r.values[1] += " " + formatBytes(vb)
+		return r
+	}
+	return []compareRow{
+		{label: "Total", values: [2]string{formatBytes(a.MemTotal), formatBytes(b.MemTotal)}},
+		row("Used", a.MemUsed(), b.MemUsed()),
+		row("Free", a.MemFree, b.MemFree),
+		row("Buffers", a.MemBuffers, b.MemBuffers),
+		row("Cached", a.MemCached, b.MemCached),
+		percentRow("Swap used", swap(a), swap(b)),
+	}
+}
+
+func compareFilesystems(a, b *stats.Stats) []compareRow {
+	used := [2]map[string]float64{{}, {}}
+	var mounts []string
+	for i, st := range []*stats.Stats{a, b} {
+		for _, fs := range st.FSInfos {
+			if _, ok := used[0][fs.MountPoint]; !ok {
+				if _, ok := used[1][fs.MountPoint]; !ok {
+					mounts = append(mounts, fs.MountPoint)
+				}
+			}
+			if total := fs.Used + fs.Free; total > 0 {
+				used[i][fs.MountPoint] = float64(fs.Used) / float64(total) * 100
+			} else {
+				used[i][fs.MountPoint] = 0
+			}
+		}
+	}
+	sort.Strings(mounts)
+
+	rows := make([]compareRow, 0, len(mounts))
+	for _, mount := range mounts {
+		ua, okA := used[0][mount]
+		ub, okB := used[1][mount]
+		if !okA || !okB {
+			row := compareRow{label: mount, values: [2]string{"not mounted", "not mounted"}, differ: true}
+			if okA {
+				row.values[0] = fmt.Sprintf("%5.1f%% used", ua)
+			}
+			if okB {
+				row.values[1] = fmt.Sprintf("%5.1f%% used", ub)
+			}
+			rows = append(rows, row)
+			continue
+		}
+		row := percentRow(mount, ua, ub)
+		row.values[0] += " used"
+		row.values[1] += " used"
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func compareInterfaces(a, b *stats.Stats) []compareRow {
+	seen := make(map[string]bool)
+	var names []string
+	for _, st := range []*stats.Stats{a, b} {
+		for name := range st.NetIntf {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	rows := make([]compareRow, 0, len(names))
+	for _, name := range names {
+		ia, okA := a.NetIntf[name]
+		ib, okB := b.NetIntf[name]
+		row := compareRow{label: name, differ: !okA || !okB}
+		for i, intf := range []struct {
+			info stats.NetIntfInfo
+			ok   bool
+		}{{ia, okA}, {ib, okB}} {
+			if !intf.ok {
+				row.values[i] = "missing"
+				continue
+			}
+			row.values[i] = fmt.Sprintf("↓%s ↑%s", formatRate(intf.info.RxRate), formatRate(intf.info.TxRate))
+		}
+		if okA && okB {
+			row.differ = ratioDiffers(ia.RxRate, ib.RxRate, diffMinRate) ||
+				ratioDiffers(ia.TxRate, ib.TxRate, diffMinRate)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// compareCgroups lines up the top level cgroups by path
+func compareCgroups(a, b *stats.Stats) []compareRow {
+	groups := [2]map[string]*stats.Cgroup{{}, {}}
+	var paths []string
+	for i, st := range []*stats.Stats{a, b} {
+		for _, cg := range st.Cgroups {
+			if groups[0][cg.Path] == nil && groups[1][cg.Path] == nil {
+				paths = append(paths, cg.Path)
+			}
+			groups[i][cg.Path] = cg
+		}
+	}
+	sort.Strings(paths)
+
+	rows := make([]compareRow, 0, len(paths))
+	for _, path := range paths {
+		ga, gb := groups[0][path], groups[1][path]
+		row := compareRow{label: strings.TrimPrefix(path, "/sys/fs/cgroup/"), differ: ga == nil || gb == nil}
+		for i, cg := range []*stats.Cgroup{ga, gb} {
+			if cg == nil {
+				row.values[i] = "missing"
+				continue
+			}
+			row.values[i] = fmt.Sprintf("%s mem, %.0fs cpu", formatBytes(uint64(cg.MemoryUsageCurrent)), cg.CpuUsage)
+		}
+		if ga != nil && gb != nil {
+			row.differ = ratioDiffers(float64(ga.MemoryUsageCurrent), float64(gb.MemoryUsageCurrent), 1<<20)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
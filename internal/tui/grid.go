@@ -0,0 +1,308 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/0x0BSoD/rtop/internal/alert"
+	"github.com/0x0BSoD/rtop/internal/stats"
+)
+
+const (
+	tileWidth  = 38
+	tileHeight = 10
+)
+
+// hostStatsMsg is a statsMsg for one of the hosts of a Grid
+type hostStatsMsg struct {
+	index int
+	statsMsg
+}
+
+// Grid shows a compact tile per host and zooms into the full single host
+// view of the selected one
+type Grid struct {
+	UpdateInterval time.Duration
+	hosts          []Model
+	fetching       []bool
+	cursor         int // index of the selected host
+	zoomed         bool
+	summary        bool       // fleet table instead of tiles
+	sortBy         sortColumn // of the fleet table
+	reverse        bool
+	width          int
+	height         int
+	bar            progress.Model
+}
+
+// NewGrid sets up the grid for fetchers that already hold a first round of
+// stats, or are reconnecting
+func NewGrid(fetchers []*stats.SshFetcher, interval time.Duration) Grid {
+	g := Grid{
+		UpdateInterval: interval,
+		hosts:          make([]Model, len(fetchers)),
+		fetching:       make([]bool, len(fetchers)),
+		bar:            progress.New(progress.WithScaledGradient("#FF7CCB", "#FDFF8C"), progress.WithWidth(tileWidth-14)),
+	}
+	for i, f := range fetchers {
+		g.hosts[i] = NewModel(f, interval)
+	}
+	return g
+}
+
+func (g Grid) Init() tea.Cmd {
+	tea.SetWindowTitle("rtop")
+	return tea.Tick(g.UpdateInterval, func(t time.Time) tea.Msg {
+		return tickMsg(t)
+	})
+}
+
+func fetchHostCmd(index int, fetcher *stats.SshFetcher) tea.Cmd {
+	fetch := fetchStatsCmd(fetcher)
+	return func() tea.Msg {
+		return hostStatsMsg{index: index, statsMsg: fetch().(statsMsg)}
+	}
+}
+
+func (g Grid) columns() int {
+	if cols := g.width / tileWidth; cols > 1 {
+		return cols
+	}
+	return 1
+}
+
+func (g Grid) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds []tea.Cmd
+
+	switch msg := msg.(type) {
+	case hostStatsMsg:
+		g.fetching[msg.index] = false
+		h, _ := g.hosts[msg.index].Update(msg.statsMsg)
+		g.hosts[msg.index] = h.(Model)
+	case tea.KeyMsg:
+		if key.Matches(msg, keys.Quit) {
+			return g, tea.Quit
+		}
+		if g.zoomed {
+			if key.Matches(msg, keys.Back) {
+				g.zoomed = false
+				break
+			}
+			h, cmd := g.hosts[g.cursor].Update(msg)
+			g.hosts[g.cursor] = h.(Model)
+			cmds = append(cmds, cmd)
+			break
+		}
+		if g.summary {
+			g = g.updateSummary(msg)
+			break
+		}
+		switch {
+		case key.Matches(msg, keys.Summary):
+			g.summary = true
+		case key.Matches(msg, keys.Left):
+			if g.cursor > 0 {
+				g.cursor--
+			}
+		case key.Matches(msg, keys.Right):
+			if g.cursor < len(g.hosts)-1 {
+				g.cursor++
+			}
+		case key.Matches(msg, keys.Up):
+			if g.cursor >= g.columns() {
+				g.cursor -= g.columns()
+			}
+		case key.Matches(msg, keys.Down):
+			if g.cursor+g.columns() < len(g.hosts) {
+				g.cursor += g.columns()
+			}
+		case key.Matches(msg, keys.Zoom):
+			g.zoomed = true
+		}
+	case tea.WindowSizeMsg:
+		g.width = msg.Width
+		g.height = msg.Height
+		for i := range g.hosts {
+			h, cmd := g.hosts[i].Update(msg)
+			g.hosts[i] = h.(Model)
+			cmds = append(cmds, cmd)
+		}
+	case tickMsg:
+		for i, m := range g.hosts {
+			// A slow host must not pile up requests, and the cgroup tree
+			// stays put while it is being browsed
+			if g.fetching[i] || (g.zoomed && i == g.cursor && m.cgroupView) {
+				continue
+			}
+			g.fetching[i] = true
+			cmds = append(cmds, fetchHostCmd(i, m.SshFetcher))
+		}
+		cmds = append(cmds,
+			tea.Tick(g.UpdateInterval, func(t time.Time) tea.Msg {
+				return tickMsg(t)
+			}),
+		)
+	}
+
+	return g, tea.Batch(cmds...)
+}
+
+// updateSummary handles keys while the fleet table is shown, where up and
+// down follow the sorted rows
+func (g Grid) updateSummary(msg tea.KeyMsg) Grid {
+	order := g.order()
+	pos := 0
+	for i, index := range order {
+		if index == g.cursor {
+			pos = i
+		}
+	}
+	switch {
+	case key.Matches(msg, keys.Summary):
+		g.summary = false
+	case key.Matches(msg, keys.Up):
+		if pos > 0 {
+			g.cursor = order[pos-1]
+		}
+	case key.Matches(msg, keys.Down):
+		if pos < len(order)-1 {
+			g.cursor = order[pos+1]
+		}
+	case key.Matches(msg, keys.Sort):
+		g.sortBy = (g.sortBy + 1) % sortColumns
+	case key.Matches(msg, keys.Reverse):
+		g.reverse = !g.reverse
+	case key.Matches(msg, keys.Zoom):
+		g.zoomed = true
+	}
+	return g
+}
+
+func (g Grid) View() string {
+	if g.zoomed {
+		m := g.hosts[g.cursor]
+		help := helpStyle.Render("↑/↓: Navigate  ←/→: Back/Enter  c: Toggle  i: Capabilities  a: Alerts  esc: All hosts  q: Quit")
+		return fmt.Sprintf("%s\n%s", m.viewport.View(), help)
+	}
+
+	reachable := 0
+	for _, m := range g.hosts {
+		if !m.status.Reconnecting {
+			reachable++
+		}
+	}
+	header := fmt.Sprintf("%s %d of %d reachable\n",
+		keywordStyle.Render("Hosts"), reachable, len(g.hosts))
+
+	if g.summary {
+		help := helpStyle.Render(fmt.Sprintf("↑/↓: Select  enter: Zoom  s: Sort (%s)  r: Reverse  t: Tiles  q: Quit", sortNames[g.sortBy]))
+		return fmt.Sprintf("%s%s\n%s", header, g.viewSummary(), help)
+	}
+
+	cols := g.columns()
+	var rows []string
+	for start := 0; start < len(g.hosts); start += cols {
+		var tiles []string
+		for i := start; i < start+cols && i < len(g.hosts); i++ {
+			tiles = append(tiles, g.viewTile(i))
+		}
+		rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, tiles...))
+	}
+
+	// Scroll so the selected tile stays on screen
+	visible := (g.height - 2) / tileHeight
+	if visible < 1 {
+		visible = 1
+	}
+	first := g.cursor/cols - visible + 1
+	if first < 0 {
+		first = 0
+	}
+	last := first + visible
+	if last > len(rows) {
+		last = len(rows)
+	}
+
+	help := helpStyle.Render("←/→/↑/↓: Select  enter: Zoom  t: Table  q: Quit")
+	return fmt.Sprintf("%s%s\n%s", header, lipgloss.JoinVertical(lipgloss.Left, rows[first:last]...), help)
+}
+
+func (g Grid) viewTile(i int) string {
+	m := g.hosts[i]
+	st := m.stats
+
+	var sb strings.Builder
+	name := m.SshFetcher.Name
+	if len(st.Hostname) > 0 && st.Hostname != name {
+		name += " (" + st.Hostname + ")"
+	}
+	if len(name) > tileWidth-4 {
+		name = name[:tileWidth-5] + "…"
+	}
+	sb.WriteString(titleStyle.Render(name))
+	sb.WriteString("\n")
+
+	switch {
+	case m.status.Reconnecting && m.status.LastUpdate.IsZero():
+		sb.WriteString(warnStyle.Render(" UNREACHABLE "))
+		sb.WriteString("\n" + errorStyle.Render(fmt.Sprint(m.status.LastError)))
+		return g.tileStyle(i).Height(tileHeight - 2).Render(sb.String())
+	case m.status.Reconnecting:
+		sb.WriteString(warnStyle.Render(fmt.Sprintf(" RECONNECTING (%d) ", m.status.Attempts)))
+		sb.WriteString(fmt.Sprintf(" since %s\n", m.status.LastUpdate.Format("15:04:05")))
+	default:
+		sb.WriteString(fmt.Sprintf("%s %s\n", labelStyle.Render("Up  "), formatDurationWithDays(st.Uptime)))
+	}
+
+	sb.WriteString(fmt.Sprintf("%s %s\n", labelStyle.Render("CPU "), g.bar.ViewAs(cpuUsed(st))))
+	sb.WriteString(fmt.Sprintf("%s %s\n", labelStyle.Render("Mem "), g.bar.ViewAs(memUsed(st))))
+	sb.WriteString(fmt.Sprintf("%s %s %s %s\n", labelStyle.Render("Load"), st.Load1, st.Load5, st.Load10))
+
+	fullest, fullestPct := fullestFS(st)
+	mount := fullest.MountPoint
+	if max := tileWidth - 16; len(mount) > max {
+		mount = "…" + mount[len(mount)-max+1:]
+	}
+	sb.WriteString(fmt.Sprintf("%s %3.0f%% %s\n", labelStyle.Render("Disk"), fullestPct*100, mount))
+
+	rx, tx := netRates(st)
+	sb.WriteString(fmt.Sprintf("%s ↓%s ↑%s", labelStyle.Render("Net "), formatRate(rx), formatRate(tx)))
+
+	// Both on the last line, so the tile keeps its height
+	var notes []string
+	if firing := len(m.alerting()); firing > 0 {
+		notes = append(notes, warnStyle.Render(fmt.Sprintf(" %d ALERT(S) ", firing)))
+	}
+	if failing, _ := failingCollectors(m); failing > 0 {
+		notes = append(notes, errorStyle.Render(fmt.Sprintf("%d collector(s) failing", failing)))
+	}
+	if len(notes) > 0 {
+		sb.WriteString("\n" + strings.Join(notes, " "))
+	}
+
+	return g.tileStyle(i).Height(tileHeight - 2).Render(sb.String())
+}
+
+func (g Grid) tileStyle(i int) lipgloss.Style {
+	if i == g.cursor {
+		return selectedTileStyle
+	}
+	if len(g.hosts[i].alerting()) > 0 {
+		return alertTileStyle
+	}
+	return tileStyle
+}
+
+// WithAlerts shows the alerts of engine on every host
+func (g Grid) WithAlerts(engine *alert.Engine) Grid {
+	for i := range g.hosts {
+		g.hosts[i].Alerts = engine
+	}
+	return g
+}
@@ -0,0 +1,126 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/0x0BSoD/rtop/internal/stats"
+)
+
+// fakeTransport answers commands that read one of its files, and counts the
+// hostname up on every round so that each one writes new stats
+type fakeTransport struct {
+	mu     sync.Mutex
+	rounds int
+	files  map[string]string
+}
+
+func newFakeTransport() *fakeTransport {
+	return &fakeTransport{files: map[string]string{
+		"/proc/uptime":  "12345.67 54321.00\n",
+		"/proc/loadavg": "0.50 0.40 0.30 2/300 4242\n",
+		"/proc/meminfo": "MemTotal: 2048 kB\nMemFree: 512 kB\nBuffers: 128 kB\nCached: 256 kB\nSwapTotal: 0 kB\nSwapFree: 0 kB\n",
+		"/proc/stat":    "cpu  100 0 50 800 10 0 5 3 0 0\ncpu0 100 0 50 800 10 0 5 3 0 0\n",
+		"/proc/net/dev": "Inter-|   Receive\n face |bytes\n  eth0: 1000 10 0 0 0 0 0 0 2000 20 0 0 0 0 0 0\n",
+		"ip -o addr":    "2: eth0    inet 10.0.0.2/24 brd 10.0.0.255 scope global eth0\n",
+	}}
+}
+
+func (t *fakeTransport) RunCommand(ctx context.Context, command string) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if strings.Contains(command, "hostname") {
+		t.rounds++
+		return fmt.Sprintf("box-%d\n", t.rounds), nil
+	}
+	for key, content := range t.files {
+		if strings.Contains(command, key) {
+			return content, nil
+		}
+	}
+	return "", fmt.Errorf("%s: command not found", command)
+}
+
+func (t *fakeTransport) RunCommandInput(ctx context.Context, command string, stdin io.Reader) (string, error) {
+	return t.RunCommand(ctx, command)
+}
+
+func (t *fakeTransport) Close() error {
+	return nil
+}
+
+// TestGridRendersWhileFetching renders every view of a grid while its hosts
+// collect a round, which the race detector checks
+func TestGridRendersWhileFetching(t *testing.T) {
+	fetchers := make([]*stats.SshFetcher, 2)
+	for i := range fetchers {
+		fetchers[i] = stats.NewSshFetcher(newFakeTransport())
+		fetchers[i].Name = fmt.Sprintf("host%d", i)
+		fetchers[i].GetAllStats(context.Background())
+	}
+
+	var model tea.Model = NewGrid(fetchers, time.Second)
+	model, _ = model.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+
+	for round := 0; round < 5; round++ {
+		msgs := make(chan tea.Msg, len(fetchers))
+		for i, f := range fetchers {
+			go func() {
+				msgs <- fetchHostCmd(i, f)()
+			}()
+		}
+		for received := 0; received < len(fetchers); {
+			select {
+			case msg := <-msgs:
+				model, _ = model.Update(msg)
+				received++
+			default:
+				g := model.(Grid)
+				g.View()
+				g.summary = true
+				g.View()
+				g.summary, g.zoomed = false, true
+				g.View()
+			}
+		}
+	}
+
+	view := model.(Grid).View()
+	for _, want := range []string{"host0 (box-6)", "host1 (box-6)"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view does not show %q:\n%s", want, view)
+		}
+	}
+}
+
+func TestCompareRendersWhileFetching(t *testing.T) {
+	a := stats.NewSshFetcher(newFakeTransport())
+	b := stats.NewSshFetcher(newFakeTransport())
+	a.Name, b.Name = "a", "b"
+	var model tea.Model = NewCompare(a, b, time.Second)
+	model, _ = model.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+
+	msg := make(chan tea.Msg)
+	go func() {
+		msg <- fetchHostCmd(0, a)()
+	}()
+	for done := false; !done; {
+		select {
+		case m := <-msg:
+			model, _ = model.Update(m)
+			done = true
+		default:
+			model.View()
+		}
+	}
+	if view := model.View(); !strings.Contains(view, "a (box-1)") {
+		t.Errorf("view does not show the new hostname:\n%s", view)
+	}
+}
@@ -1,12 +1,15 @@
 package tui
 
 import (
+	"context"
 	"fmt"
 	"github.com/0x0BSoD/rtop/internal/stats"
 	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/table"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -39,6 +42,10 @@ func formatBytes(bytes uint64) string {
 	return fmt.Sprintf("%.2f %cb", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
 
+func formatRate(bytesPerSec float64) string {
+	return formatBytes(uint64(bytesPerSec)) + "/s"
+}
+
 func resizeBars(bars map[string]progress.Model, width int) {
 	for _, bar := range bars {
 		bar.Width = width - 2*2 - 4
@@ -48,6 +55,72 @@ func resizeBars(bars map[string]progress.Model, width int) {
 	}
 }
 
+func fsRows(st *stats.Stats) []table.Row {
+	rows := make([]table.Row, 0, len(st.FSInfos))
+	for _, d := range st.FSInfos {
+		rows = append(rows, table.Row{
+			d.Device, d.MountPoint, formatBytes(d.Free), formatBytes(d.Used + d.Free),
+		})
+	}
+	return rows
+}
+
+func netRows(st *stats.Stats) []table.Row {
+	names := make([]string, 0, len(st.NetIntf))
+	for n := range st.NetIntf {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	rows := make([]table.Row, 0, len(names))
+	for _, n := range names {
+		d := st.NetIntf[n]
+		rows = append(rows, table.Row{
+			n, d.IPv4, d.IPv6, formatBytes(d.Rx), formatBytes(d.Tx),
+		})
+	}
+	return rows
+}
+
+// unavailable renders an indicator for the collectors behind a panel that
+// failed in the last round, or nothing when they all worked
+func (m Model) unavailable(collectors ...string) string {
+	var reasons []string
+	for _, c := range collectors {
+		if err, ok := m.errs[c]; ok {
+			reasons = append(reasons, err.Reason())
+		}
+	}
+	if len(reasons) == 0 {
+		return ""
+	}
+	return errorStyle.Render("unavailable: "+strings.Join(reasons, "; ")) + "\n"
+}
+
+// NewModel sets up the single host view for a fetcher that already holds a
+// first round of stats
+func NewModel(fetcher *stats.SshFetcher, interval time.Duration) Model {
+	bars := make(map[string]progress.Model, 10)
+	for _, name := range []string{"total", "system", "user", "idle", "irq", "softIrq", "iowait", "guest", "nice", "steal"} {
+		bars[name] = progress.New(progress.WithScaledGradient("#FF7CCB", "#FDFF8C"))
+	}
+
+	view := fetcher.View()
+	m := Model{
+		SshFetcher:     fetcher,
+		UpdateInterval: interval,
+		Bars:           bars,
+		stats:          view.Stats,
+		status:         view.Status,
+		caps:           view.Caps,
+		collectors:     view.Collectors,
+		degraded:       view.Degraded,
+	}
+	InitFsTable(&m)
+	InitNetTable(&m)
+	return m
+}
+
 func InitFsTable(m *Model) {
 	columns := []table.Column{
 		{Title: "Device", Width: 30},
@@ -56,16 +129,9 @@ func InitFsTable(m *Model) {
 		{Title: "Total", Width: 10},
 	}
 
-	rows := make([]table.Row, 0, len(m.SshFetcher.Stats.FSInfos))
-	for _, d := range m.SshFetcher.Stats.FSInfos {
-		rows = append(rows, table.Row{
-			d.Device, d.MountPoint, formatBytes(d.Free), formatBytes(d.Used + d.Free),
-		})
-	}
-
 	t := table.New(
 		table.WithColumns(columns),
-		table.WithRows(rows),
+		table.WithRows(fsRows(m.stats)),
 		table.WithFocused(false),
 		table.WithHeight(10),
 	)
@@ -94,16 +160,9 @@ func InitNetTable(m *Model) {
 		{Title: "TX", Width: 10},
 	}
 
-	rows := make([]table.Row, 0, len(m.SshFetcher.Stats.FSInfos))
-	for n, d := range m.SshFetcher.Stats.NetIntf {
-		rows = append(rows, table.Row{
-			n, d.IPv4, d.IPv6, formatBytes(d.Rx), formatBytes(d.Tx),
-		})
-	}
-
 	t := table.New(
 		table.WithColumns(columns),
-		table.WithRows(rows),
+		table.WithRows(netRows(m.stats)),
 		table.WithFocused(false),
 		table.WithHeight(10),
 	)
@@ -125,10 +184,10 @@ func InitNetTable(m *Model) {
 
 func fetchStatsCmd(fetcher *stats.SshFetcher) tea.Cmd {
 	return func() tea.Msg {
-		err := fetcher.GetAllStats()
+		err := fetcher.GetAllStats(context.Background())
 		return statsMsg{
-			Stats: fetcher.Stats,
-			Errs:  err,
+			HostView: fetcher.View(),
+			Errs:     err,
 		}
 	}
 }
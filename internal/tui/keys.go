@@ -3,12 +3,19 @@ package tui
 import "github.com/charmbracelet/bubbles/key"
 
 type keyMap struct {
-	Up     key.Binding
-	Down   key.Binding
-	Left   key.Binding
-	Right  key.Binding
-	Toggle key.Binding
-	Quit   key.Binding
+	Up      key.Binding
+	Down    key.Binding
+	Left    key.Binding
+	Right   key.Binding
+	Toggle  key.Binding
+	Caps    key.Binding
+	Alerts  key.Binding
+	Zoom    key.Binding
+	Back    key.Binding
+	Summary key.Binding
+	Sort    key.Binding
+	Reverse key.Binding
+	Quit    key.Binding
 }
 
 var keys = keyMap{
@@ -32,6 +39,34 @@ var keys = keyMap{
 		key.WithKeys("c"),
 		key.WithHelp("c", "toggle metrics/cgroups"),
 	),
+	Caps: key.NewBinding(
+		key.WithKeys("i"),
+		key.WithHelp("i", "show host capabilities"),
+	),
+	Alerts: key.NewBinding(
+		key.WithKeys("a"),
+		key.WithHelp("a", "show alerts"),
+	),
+	Zoom: key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("enter", "zoom into host"),
+	),
+	Back: key.NewBinding(
+		key.WithKeys("esc"),
+		key.WithHelp("esc", "back to all hosts"),
+	),
+	Summary: key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("t", "toggle tiles/fleet table"),
+	),
+	Sort: key.NewBinding(
+		key.WithKeys("s"),
+		key.WithHelp("s", "sort by next column"),
+	),
+	Reverse: key.NewBinding(
+		key.WithKeys("r"),
+		key.WithHelp("r", "reverse sort order"),
+	),
 	Quit: key.NewBinding(
 		key.WithKeys("q", "ctrl+c"),
 		key.WithHelp("q/ctrl+c", "quit"),
@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -10,20 +11,29 @@ import (
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 
+	"github.com/0x0BSoD/rtop/internal/alert"
 	"github.com/0x0BSoD/rtop/internal/stats"
 )
 
 type tickMsg time.Time
 
+// statsMsg carries a round of stats copied from the fetcher, which the model
+// renders from while the fetcher collects the next one
 type statsMsg struct {
-	Stats *stats.Stats
-	Errs  []error
+	stats.HostView
+	Errs []error
 }
 
 type Model struct {
 	UpdateInterval time.Duration
 	SshFetcher     *stats.SshFetcher
+	Alerts         *alert.Engine // fed by the fetcher, nil without rules
 	stats          *stats.Stats
+	status         stats.ConnStatus
+	caps           *stats.Capabilities
+	collectors     []stats.CollectorState
+	degraded       map[string]string
+	errs           map[string]*stats.CollectorError
 	width          int
 	height         int
 	Bars           map[string]progress.Model
@@ -33,6 +43,8 @@ type Model struct {
 	path           []*stats.Cgroup
 	selected       *stats.Cgroup
 	cgroupView     bool
+	capsView       bool
+	alertsView     bool
 	cursor         int
 }
 
@@ -58,6 +70,21 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case statsMsg:
 		m.stats = msg.Stats
+		m.status = msg.Status
+		m.caps = msg.Caps
+		m.collectors = msg.Collectors
+		m.degraded = msg.Degraded
+		if !m.status.Reconnecting {
+			m.errs = make(map[string]*stats.CollectorError, len(msg.Errs))
+			for _, err := range msg.Errs {
+				var collectorErr *stats.CollectorError
+				if errors.As(err, &collectorErr) {
+					m.errs[collectorErr.Collector] = collectorErr
+				}
+			}
+		}
+		m.fsTable.SetRows(fsRows(msg.Stats))
+		m.netTable.SetRows(netRows(msg.Stats))
 	case tea.KeyMsg:
 		switch {
 		case key.Matches(msg, keys.Quit):
@@ -94,6 +121,16 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		case key.Matches(msg, keys.Toggle):
 			m.cgroupView = !m.cgroupView
+			m.capsView = false
+			m.alertsView = false
+		case key.Matches(msg, keys.Caps):
+			m.capsView = !m.capsView
+			m.cgroupView = false
+			m.alertsView = false
+		case key.Matches(msg, keys.Alerts):
+			m.alertsView = !m.alertsView
+			m.cgroupView = false
+			m.capsView = false
 		}
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
@@ -124,7 +161,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		)
 	}
 
-	if m.cgroupView {
+	if m.alertsView {
+		m.viewport.SetContent(m.viewAlerts())
+	} else if m.capsView {
+		m.viewport.SetContent(m.viewCapabilities())
+	} else if m.cgroupView {
 		m.viewport.SetContent(m.viewCgroups())
 	} else {
 		m.viewport.SetContent(m.viewMetrics())
@@ -135,7 +176,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m Model) View() string {
-	help := helpStyle.Render("↑/↓: Navigate  ←/→: Back/Enter  c: Toggle  q: Quit")
+	help := helpStyle.Render("↑/↓: Navigate  ←/→: Back/Enter  c: Toggle  i: Capabilities  a: Alerts  q: Quit")
 
 	return fmt.Sprintf("%s\n%s", m.viewport.View(), help)
 }
@@ -2,7 +2,12 @@ package tui
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/charmbracelet/lipgloss"
+
+	"github.com/0x0BSoD/rtop/internal/alert"
 )
 
 func (m Model) viewMetrics() string {
@@ -17,68 +22,88 @@ func (m Model) viewMetrics() string {
 	contentHeight := m.height - (verticalPadding * 2)
 
 	// Header ---
-	outHeader += fmt.Sprintf("%s %s ", keywordStyle.Render("HostName"), m.SshFetcher.Stats.Hostname)
-	outHeader += fmt.Sprintf("%s %s %s %s ", keywordStyle.Render("Load Average"), m.SshFetcher.Stats.Load1, m.SshFetcher.Stats.Load5, m.SshFetcher.Stats.Load10)
-	outHeader += fmt.Sprintf("%s %s\n", keywordStyle.Render("Uptime"), formatDurationWithDays(m.SshFetcher.Stats.Uptime))
-	outHeader += fmt.Sprintf("%s %s running of %s total\n\n", keywordStyle.Render("Processes"), m.SshFetcher.Stats.RunningProcs, m.SshFetcher.Stats.TotalProcs)
+	if m.status.Reconnecting {
+		outHeader += warnStyle.Render(fmt.Sprintf(" RECONNECTING (attempt %d): %v ", m.status.Attempts, m.status.LastError))
+		if !m.status.LastUpdate.IsZero() {
+			outHeader += fmt.Sprintf(" showing data from %s", m.status.LastUpdate.Format("15:04:05"))
+		}
+		outHeader += "\n"
+	}
+	outHeader += fmt.Sprintf("%s %s ", keywordStyle.Render("HostName"), m.stats.Hostname)
+	outHeader += fmt.Sprintf("%s %s %s %s ", keywordStyle.Render("Load Average"), m.stats.Load1, m.stats.Load5, m.stats.Load10)
+	outHeader += fmt.Sprintf("%s %s\n", keywordStyle.Render("Uptime"), formatDurationWithDays(m.stats.Uptime))
+	outHeader += fmt.Sprintf("%s %s running of %s total\n", keywordStyle.Render("Processes"), m.stats.RunningProcs, m.stats.TotalProcs)
+	outHeader += m.unavailable("hostname", "uptime", "load")
+	outHeader += m.alertLines(alert.PanelHeader)
+	if len(m.degraded) > 0 {
+		names := make([]string, 0, len(m.degraded))
+		var reason string
+		for name, r := range m.degraded {
+			names = append(names, name)
+			reason = r
+		}
+		sort.Strings(names)
+		outHeader += fmt.Sprintf("%s %s (%s)\n", keywordStyle.Render("Without sudo"), strings.Join(names, ", "), reason)
+	}
+	outHeader += "\n"
 
 	// CPU ---
 	// system
 	outCpu += fmt.Sprintf("%s %6s\n",
 		labelStyle.Render(fmt.Sprintf("%-8s", "System")),
-		m.Bars["system"].ViewAs(float64(m.SshFetcher.Stats.CPU.System)/100.0),
+		m.Bars["system"].ViewAs(float64(m.stats.CPU.System)/100.0),
 	)
 	// user
 	outCpu += fmt.Sprintf("%s %6s\n",
 		labelStyle.Render(fmt.Sprintf("%-8s", "User")),
-		m.Bars["user"].ViewAs(float64(m.SshFetcher.Stats.CPU.User)/100.0),
+		m.Bars["user"].ViewAs(float64(m.stats.CPU.User)/100.0),
 	)
 	// irq
 	outCpu += fmt.Sprintf("%s %6s\n",
 		labelStyle.Render(fmt.Sprintf("%-8s", "Irq")),
-		m.Bars["irq"].ViewAs(float64(m.SshFetcher.Stats.CPU.Irq)/100.0),
+		m.Bars["irq"].ViewAs(float64(m.stats.CPU.Irq)/100.0),
 	)
 	// softIrq
 	outCpu += fmt.Sprintf("%s %6s\n",
 		labelStyle.Render(fmt.Sprintf("%-8s", "SoftIrq")),
-		m.Bars["softIrq"].ViewAs(float64(m.SshFetcher.Stats.CPU.SoftIrq)/100.0),
+		m.Bars["softIrq"].ViewAs(float64(m.stats.CPU.SoftIrq)/100.0),
 	)
 	// iowait
 	outCpu += fmt.Sprintf("%s %6s\n",
 		labelStyle.Render(fmt.Sprintf("%-8s", "Iowait")),
-		m.Bars["iowait"].ViewAs(float64(m.SshFetcher.Stats.CPU.Iowait)/100.0),
+		m.Bars["iowait"].ViewAs(float64(m.stats.CPU.Iowait)/100.0),
 	)
 	// guest
 	outCpu += fmt.Sprintf("%s %6s\n",
 		labelStyle.Render(fmt.Sprintf("%-8s", "Guest")),
-		m.Bars["guest"].ViewAs(float64(m.SshFetcher.Stats.CPU.Guest)/100.0),
+		m.Bars["guest"].ViewAs(float64(m.stats.CPU.Guest)/100.0),
 	)
 	// nice
 	outCpu += fmt.Sprintf("%s %6s\n",
 		labelStyle.Render(fmt.Sprintf("%-8s", "Nice")),
-		m.Bars["nice"].ViewAs(float64(m.SshFetcher.Stats.CPU.Nice)/100.0),
+		m.Bars["nice"].ViewAs(float64(m.stats.CPU.Nice)/100.0),
 	)
 	// steal
 	outCpu += fmt.Sprintf("%s %6s\n",
 		labelStyle.Render(fmt.Sprintf("%-8s", "Steal")),
-		m.Bars["idle"].ViewAs(float64(m.SshFetcher.Stats.CPU.Steal)/100.0),
+		m.Bars["steal"].ViewAs(float64(m.stats.CPU.Steal)/100.0),
 	)
 	// idle
 	outCpu += fmt.Sprintf("%s %6s\n",
 		labelStyle.Render(fmt.Sprintf("%-8s", "Idle")),
-		m.Bars["idle"].ViewAs(float64(m.SshFetcher.Stats.CPU.Idle)/100.0),
+		m.Bars["idle"].ViewAs(float64(m.stats.CPU.Idle)/100.0),
 	)
 	// total
-	cpuLoad := 100.0 - m.SshFetcher.Stats.CPU.Idle
+	cpuLoad := 100.0 - m.stats.CPU.Idle
 	outCpu += fmt.Sprintf("%s %6s\n",
 		labelStyle.Render(fmt.Sprintf("%-8s", "Total")),
 		m.Bars["total"].ViewAs(float64(cpuLoad)/100.0),
 	)
 
-	cpuGroup := groupStyle.Render(
+	cpuGroup := m.groupStyle(alert.PanelCPU).Render(
 		lipgloss.JoinVertical(lipgloss.Left,
 			"CPU",
-			outCpu,
+			m.unavailable("cpu")+m.alertLines(alert.PanelCPU)+outCpu,
 		),
 	)
 
@@ -86,32 +111,32 @@ func (m Model) viewMetrics() string {
 	// free
 	outMem += fmt.Sprintf("%s %6s\n",
 		labelStyle.Render(fmt.Sprintf("%-8s", "Free")),
-		formatBytes(m.SshFetcher.Stats.MemFree))
+		formatBytes(m.stats.MemFree))
 	// used
 	outMem += fmt.Sprintf("%s %6s\n",
 		labelStyle.Render(fmt.Sprintf("%-8s", "Used")),
-		formatBytes(m.SshFetcher.Stats.MemTotal-m.SshFetcher.Stats.MemFree))
+		formatBytes(m.stats.MemUsed()))
 	// buffers
 	outMem += fmt.Sprintf("%s %6s\n",
 		labelStyle.Render(fmt.Sprintf("%-8s", "Buffers")),
-		formatBytes(m.SshFetcher.Stats.MemBuffers))
+		formatBytes(m.stats.MemBuffers))
 	// cached
 	outMem += fmt.Sprintf("%s %6s\n",
 		labelStyle.Render(fmt.Sprintf("%-8s", "Cached")),
-		formatBytes(m.SshFetcher.Stats.MemCached))
+		formatBytes(m.stats.MemCached))
 	// swap
 	outMem += fmt.Sprintf("%s %6s\n",
 		labelStyle.Render(fmt.Sprintf("%-8s", "Swap")),
-		formatBytes(m.SshFetcher.Stats.SwapTotal-m.SshFetcher.Stats.SwapFree))
+		formatBytes(m.stats.SwapTotal-m.stats.SwapFree))
 	// total
 	outMem += fmt.Sprintf("%s %6s\n",
 		labelStyle.Render(fmt.Sprintf("%-8s", "Total")),
-		formatBytes(m.SshFetcher.Stats.MemTotal))
+		formatBytes(m.stats.MemTotal))
 
-	memGroup := groupStyle.Render(
+	memGroup := m.groupStyle(alert.PanelMemory).Render(
 		lipgloss.JoinVertical(lipgloss.Left,
 			"Memory",
-			outMem,
+			m.unavailable("memory")+m.alertLines(alert.PanelMemory)+outMem,
 		),
 	)
 
@@ -129,8 +154,20 @@ func (m Model) viewMetrics() string {
 		Render(outHeader +
 			bigGroup +
 			"\n\n" +
+			m.unavailable("filesystems") +
+			m.alertLines(alert.PanelFS) +
 			m.fsTable.View() +
 			"\n\n" +
+			m.unavailable("interfaces", "interface-info") +
+			m.alertLines(alert.PanelNet) +
 			m.netTable.View(),
 		)
 }
+
+// groupStyle marks the group of panel while any of its alerts fire
+func (m Model) groupStyle(panel string) lipgloss.Style {
+	if len(m.alerting(panel)) > 0 {
+		return alertGroupStyle
+	}
+	return groupStyle
+}
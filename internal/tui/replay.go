@@ -0,0 +1,206 @@
+package tui
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/0x0BSoD/rtop/internal/stats"
+)
+
+// Frame is one recorded round of stats
+type Frame struct {
+	At       time.Time
+	Stats    *stats.Stats
+	Degraded map[string]string
+}
+
+const (
+	// maxReplayGap shortens pauses in a recording, like rtop being stopped
+	// between two sessions appended to the same file
+	maxReplayGap = 10 * time.Second
+	seekStep     = time.Minute
+	minSpeed     = 0.125
+	maxSpeed     = 64
+)
+
+var replayKeys = struct {
+	Pause, Next, Prev, Faster, Slower, Forward, Backward, Start, End key.Binding
+}{
+	Pause:    key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "pause/resume")),
+	Next:     key.NewBinding(key.WithKeys("n"), key.WithHelp("n", "step forward")),
+	Prev:     key.NewBinding(key.WithKeys("p"), key.WithHelp("p", "step back")),
+	Faster:   key.NewBinding(key.WithKeys("+", "="), key.WithHelp("+", "faster")),
+	Slower:   key.NewBinding(key.WithKeys("-"), key.WithHelp("-", "slower")),
+	Forward:  key.NewBinding(key.WithKeys("]"), key.WithHelp("]", "seek 1 minute forward")),
+	Backward: key.NewBinding(key.WithKeys("["), key.WithHelp("[", "seek 1 minute back")),
+	Start:    key.NewBinding(key.WithKeys("home", "g"), key.WithHelp("g", "go to start")),
+	End:      key.NewBinding(key.WithKeys("end", "G"), key.WithHelp("G", "go to end")),
+}
+
+// replayTickMsg moves to the next frame, unless seeking or a change of speed
+// made it stale
+type replayTickMsg struct {
+	gen int
+}
+
+// Replay drives the single host view from recorded frames instead of a live
+// connection
+type Replay struct {
+	model  Model
+	frames []Frame
+	pos    int
+	paused bool
+	speed  float64
+	gen    int
+	note   string // shown next to the position, such as other hosts in the file
+	width  int
+}
+
+func NewReplay(name string, frames []Frame, note string) Replay {
+	fetcher := stats.NewSshFetcher(nil)
+	fetcher.Name = name
+	fetcher.Stats = frames[0].Stats
+	r := Replay{
+		model:  NewModel(fetcher, time.Second),
+		frames: frames,
+		speed:  1,
+		note:   note,
+	}
+	r.show(0)
+	return r
+}
+
+func (r Replay) Init() tea.Cmd {
+	tea.SetWindowTitle("rtop replay")
+	return r.schedule()
+}
+
+// show hands frame i to the host view as if it had just been collected
+func (r *Replay) show(i int) {
+	r.pos = i
+	frame := r.frames[i]
+	errs := make([]error, 0, len(frame.Stats.Errors))
+	for _, err := range frame.Stats.Errors {
+		errs = append(errs, err)
+	}
+	view := r.model.SshFetcher.View()
+	view.Stats = frame.Stats
+	view.Status = stats.ConnStatus{LastUpdate: frame.At}
+	view.Degraded = frame.Degraded
+	m, _ := r.model.Update(statsMsg{HostView: view, Errs: errs})
+	r.model = m.(Model)
+}
+
+// schedule asks for the next frame after the recorded delay, scaled by speed
+func (r *Replay) schedule() tea.Cmd {
+	if r.paused || r.pos >= len(r.frames)-1 {
+		return nil
+	}
+	delay := r.frames[r.pos+1].At.Sub(r.frames[r.pos].At)
+	if delay > maxReplayGap {
+		delay = maxReplayGap
+	}
+	delay = time.Duration(float64(delay) / r.speed)
+	gen := r.gen
+	return tea.Tick(delay, func(time.Time) tea.Msg {
+		return replayTickMsg{gen: gen}
+	})
+}
+
+// seek shows the first frame at or after, or the last one before, d from the
+// current one
+func (r *Replay) seek(d time.Duration) {
+	target := r.frames[r.pos].At.Add(d)
+	i := r.pos
+	if d > 0 {
+		for i < len(r.frames)-1 && r.frames[i].At.Before(target) {
+			i++
+		}
+	} else {
+		for i > 0 && r.frames[i].At.After(target) {
+			i--
+		}
+	}
+	r.show(i)
+}
+
+func (r Replay) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case replayTickMsg:
+		if msg.gen != r.gen || r.paused {
+			return r, nil
+		}
+		r.show(r.pos + 1)
+		return r, r.schedule()
+	case tea.WindowSizeMsg:
+		r.width = msg.Width
+		// Room for the replay status line
+		msg.Height--
+		// The host view asks to sync the viewport for high performance
+		// rendering, which it only gets when it already has content, as
+		// replayed frames do. That scrolls the terminal, so ignore it.
+		m, _ := r.model.Update(msg)
+		r.model = m.(Model)
+		return r, nil
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keys.Quit):
+			return r, tea.Quit
+		case key.Matches(msg, replayKeys.Pause):
+			r.paused = !r.paused
+		case key.Matches(msg, replayKeys.Next):
+			r.paused = true
+			if r.pos < len(r.frames)-1 {
+				r.show(r.pos + 1)
+			}
+		case key.Matches(msg, replayKeys.Prev):
+			r.paused = true
+			if r.pos > 0 {
+				r.show(r.pos - 1)
+			}
+		case key.Matches(msg, replayKeys.Faster):
+			r.speed = min(r.speed*2, maxSpeed)
+		case key.Matches(msg, replayKeys.Slower):
+			r.speed = max(r.speed/2, minSpeed)
+		case key.Matches(msg, replayKeys.Forward):
+			r.seek(seekStep)
+		case key.Matches(msg, replayKeys.Backward):
+			r.seek(-seekStep)
+		case key.Matches(msg, replayKeys.Start):
+			r.show(0)
+		case key.Matches(msg, replayKeys.End):
+			r.show(len(r.frames) - 1)
+		default:
+			m, cmd := r.model.Update(msg)
+			r.model = m.(Model)
+			return r, cmd
+		}
+		// Drop the pending tick, it was timed for the previous frame or speed
+		r.gen++
+		return r, r.schedule()
+	}
+	return r, nil
+}
+
+func (r Replay) View() string {
+	frame := r.frames[r.pos]
+	state := fmt.Sprintf("%gx", r.speed)
+	if r.paused {
+		state = "PAUSED"
+	} else if r.pos == len(r.frames)-1 {
+		state = "END"
+	}
+	status := fmt.Sprintf("%s %s  %d/%d  %s",
+		titleStyle.Render(" REPLAY "), frame.At.Format("2006-01-02 15:04:05"), r.pos+1, len(r.frames), warnStyle.Render(state))
+	if len(r.note) > 0 {
+		status += "  " + r.note
+	}
+	help := helpStyle.Render("  space: Pause  n/p: Step  +/-: Speed  [/]: Seek  g/G: Start/End")
+	// A wrapped status line would push the host view off the screen
+	line := lipgloss.NewStyle().MaxWidth(r.width).Render(status + help)
+	return line + "\n" + r.model.View()
+}
@@ -17,6 +17,9 @@ var (
 			Padding(0).
 			Width(130)
 
+	alertGroupStyle = groupStyle.
+			BorderForeground(lipgloss.Color("#E74C3C"))
+
 	labelStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("168"))
 
@@ -33,6 +36,30 @@ var (
 			Foreground(lipgloss.Color("#25A065")).
 			Background(lipgloss.Color("#FFFDF5"))
 
+	warnStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("#FFFDF5")).
+			Background(lipgloss.Color("#C0392B"))
+
+	errorStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#E74C3C"))
+
+	tileStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("240")).
+			Padding(0, 1).
+			Width(tileWidth - 2)
+
+	selectedTileStyle = tileStyle.
+				BorderForeground(lipgloss.Color("63"))
+
+	alertTileStyle = tileStyle.
+			BorderForeground(lipgloss.Color("#E74C3C"))
+
+	diffStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("#F1C40F"))
+
 	helpStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#626262"))
 
@@ -0,0 +1,276 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/0x0BSoD/rtop/internal/stats"
+)
+
+// hostSummary is a row of the fleet table
+type hostSummary struct {
+	index       int
+	name        string
+	uptime      time.Duration
+	load        string
+	loadPerCore float64
+	cpu         float64 // percentages
+	mem         float64
+	disk        float64
+	nic         string
+	nicRate     float64 // bytes per second, in and out
+	reachable   bool
+	lastErr     string
+	failing     int // collectors failing, disabled ones aside
+}
+
+// sortColumn selects the order of the fleet table
+type sortColumn int
+
+const (
+	sortWorst sortColumn = iota
+	sortHost
+	sortUptime
+	sortLoad
+	sortCPU
+	sortMem
+	sortDisk
+	sortNet
+	sortStatus
+	sortError
+	sortColumns
+)
+
+var sortNames = [...]string{"worst", "host", "uptime", "load", "cpu", "mem", "disk", "net", "status", "error"}
+
+func fullestFS(st *stats.Stats) (stats.FSInfo, float64) {
+	var fullest stats.FSInfo
+	var pct float64
+	for _, fs := range st.FSInfos {
+		if total := fs.Used + fs.Free; total > 0 && float64(fs.Used)/float64(total) >= pct {
+			fullest, pct = fs, float64(fs.Used)/float64(total)
+		}
+	}
+	return fullest, pct
+}
+
+// netRates sums up traffic over all interfaces but loopback
+func netRates(st *stats.Stats) (rx, tx float64) {
+	for name, intf := range st.NetIntf {
+		if name != "lo" {
+			rx += intf.RxRate
+			tx += intf.TxRate
+		}
+	}
+	return
+}
+
+// cpuUsed is 0 until two samples were taken, rather than 100% busy
+func cpuUsed(st *stats.Stats) float64 {
+	if st.CPU == (stats.CPUInfo{}) {
+		return 0
+	}
+	return float64(100-st.CPU.Idle) / 100
+}
+
+func memUsed(st *stats.Stats) float64 {
+	if st.MemTotal == 0 {
+		return 0
+	}
+	return float64(st.MemUsed()) / float64(st.MemTotal)
+}
+
+// failingCollectors counts the collectors that failed, leaving out those
+// disabled because the host does not support them
+func failingCollectors(m Model) (int, string) {
+	names := make([]string, 0, len(m.errs))
+	for name, err := range m.errs {
+		if !errors.Is(err, stats.ErrUnsupported) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return 0, ""
+	}
+	sort.Strings(names)
+	return len(names), names[0] + ": " + m.errs[names[0]].Reason()
+}
+
+func summarize(index int, m Model) hostSummary {
+	st := m.stats
+	s := hostSummary{
+		index:     index,
+		name:      m.SshFetcher.Name,
+		uptime:    st.Uptime,
+		load:      st.Load1,
+		cpu:       cpuUsed(st) * 100,
+		mem:       memUsed(st) * 100,
+		reachable: !m.status.Reconnecting,
+	}
+	if load, err := strconv.ParseFloat(st.Load1, 64); err == nil && st.Cores > 0 {
+		s.loadPerCore = load / float64(st.Cores)
+	}
+	_, disk := fullestFS(st)
+	s.disk = disk * 100
+	for name, intf := range st.NetIntf {
+		if rate := intf.RxRate + intf.TxRate; name != "lo" && rate >= s.nicRate {
+			s.nic, s.nicRate = name, rate
+		}
+	}
+	s.failing, s.lastErr = failingCollectors(m)
+	if !s.reachable {
+		s.lastErr = fmt.Sprint(m.status.LastError)
+	}
+	return s
+}
+
+// severity ranks how sick a host looks: unreachable hosts first, then
+// failing collectors, then whichever resource is closest to exhaustion
+func (s hostSummary) severity() float64 {
+	if !s.reachable {
+		return 1000
+	}
+	worst := s.cpu
+	for _, v := range []float64{s.mem, s.disk, s.loadPerCore * 100} {
+		if v > worst {
+			worst = v
+		}
+	}
+	return float64(s.failing)*100 + worst
+}
+
+// less orders two rows for a column, numbers largest first
+func (col sortColumn) less(a, b hostSummary) bool {
+	switch col {
+	case sortHost:
+		return a.name < b.name
+	case sortUptime:
+		return a.uptime > b.uptime
+	case sortLoad:
+		return a.loadPerCore > b.loadPerCore
+	case sortCPU:
+		return a.cpu > b.cpu
+	case sortMem:
+		return a.mem > b.mem
+	case sortDisk:
+		return a.disk > b.disk
+	case sortNet:
+		return a.nicRate > b.nicRate
+	case sortStatus:
+		return !a.reachable && b.reachable
+	case sortError:
+		return a.lastErr > b.lastErr
+	default:
+		return a.severity() > b.severity()
+	}
+}
+
+// summaries returns a row per host in display order
+func (g Grid) summaries() []hostSummary {
+	rows := make([]hostSummary, len(g.hosts))
+	for i, m := range g.hosts {
+		rows[i] = summarize(i, m)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if g.reverse {
+			return g.sortBy.less(rows[j], rows[i])
+		}
+		return g.sortBy.less(rows[i], rows[j])
+	})
+	return rows
+}
+
+// order lists host indexes in display order
+func (g Grid) order() []int {
+	rows := g.summaries()
+	order := make([]int, len(rows))
+	for i, r := range rows {
+		order[i] = r.index
+	}
+	return order
+}
+
+func (g Grid) viewSummary() string {
+	rows := g.summaries()
+
+	title := func(col sortColumn, name string) string {
+		if g.sortBy != col {
+			return name
+		}
+		if g.reverse {
+			return name + " ▲"
+		}
+		return name + " ▼"
+	}
+	columns := []table.Column{
+		{Title: title(sortHost, "Host"), Width: 24},
+		{Title: title(sortUptime, "Uptime"), Width: 12},
+		{Title: title(sortLoad, "Load/Cores"), Width: 11},
+		{Title: title(sortCPU, "CPU"), Width: 6},
+		{Title: title(sortMem, "Mem"), Width: 6},
+		{Title: title(sortDisk, "Disk"), Width: 6},
+		{Title: title(sortNet, "Top NIC"), Width: 20},
+		{Title: title(sortStatus, "Status"), Width: 11},
+		{Title: title(sortError, "Last error"), Width: 40},
+	}
+
+	cursor := 0
+	tableRows := make([]table.Row, len(rows))
+	for i, r := range rows {
+		if r.index == g.cursor {
+			cursor = i
+		}
+		name := r.name
+		if st := g.hosts[r.index].stats; len(st.Hostname) > 0 && st.Hostname != name {
+			name += " (" + st.Hostname + ")"
+		}
+		status := "up"
+		if !r.reachable {
+			status = "UNREACHABLE"
+		}
+		nic := ""
+		if len(r.nic) > 0 {
+			nic = fmt.Sprintf("%s %s", r.nic, formatRate(r.nicRate))
+		}
+		tableRows[i] = table.Row{
+			name, "-", "-", "-", "-", "-", nic, status,
+			strings.ReplaceAll(r.lastErr, "\n", " "),
+		}
+		// Hosts that never answered have nothing to show
+		if st := g.hosts[r.index].stats; st.Uptime > 0 {
+			tableRows[i][1] = formatDurationWithDays(r.uptime)
+			tableRows[i][2] = fmt.Sprintf("%s/%d", r.load, st.Cores)
+			tableRows[i][3] = fmt.Sprintf("%.0f%%", r.cpu)
+			tableRows[i][4] = fmt.Sprintf("%.0f%%", r.mem)
+			tableRows[i][5] = fmt.Sprintf("%.0f%%", r.disk)
+		}
+	}
+
+	height := g.height - 4
+	if height < 3 {
+		height = 3
+	}
+	t := table.New(
+		table.WithColumns(columns),
+		table.WithRows(tableRows),
+		table.WithFocused(true),
+		table.WithHeight(height),
+	)
+	s := table.DefaultStyles()
+	s.Header = s.Header.
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(lipgloss.Color("240")).
+		BorderBottom(true).
+		Bold(true)
+	t.SetStyles(s)
+	t.SetCursor(cursor)
+
+	return t.View()
+}
@@ -0,0 +1,180 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/0x0BSoD/rtop/internal/alert"
+)
+
+// hostSummary is an entry of /api/v1/hosts
+type hostSummary struct {
+	Host       string     `json:"host"`
+	Hostname   string     `json:"hostname,omitempty"`
+	Reachable  bool       `json:"reachable"`
+	LastUpdate *time.Time `json:"last_update,omitempty"`
+	Alerts     []string   `json:"alerts,omitempty"`
+}
+
+type point struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+type metricHistory struct {
+	Host   string  `json:"host"`
+	Metric string  `json:"metric"`
+	Points []point `json:"points"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func apiError(w http.ResponseWriter, status int, format string, args ...any) {
+	writeJSON(w, status, map[string]string{"error": fmt.Sprintf(format, args...)})
+}
+
+// lookup finds a host by the name it was given on the command line
+func (d *Dashboard) lookup(name string) (int, bool) {
+	for i, f := range d.fetchers {
+		if f.Name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (d *Dashboard) serveHosts(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	hosts := make([]hostSummary, len(d.fetchers))
+	for i, f := range d.fetchers {
+		last := d.hosts[i].last
+		hosts[i] = hostSummary{Host: f.Name, Hostname: last.Hostname, Alerts: last.Alerts}
+		switch {
+		case d.hosts[i].encoded == nil:
+		case last.Reconnecting:
+			hosts[i].LastUpdate = last.LastUpdate
+		default:
+			hosts[i].Reachable = true
+			hosts[i].LastUpdate = &last.Timestamp
+		}
+	}
+	d.mu.Unlock()
+	writeJSON(w, http.StatusOK, hosts)
+}
+
+func (d *Dashboard) serveSnapshot(w http.ResponseWriter, r *http.Request) {
+	i, ok := d.lookup(r.PathValue("host"))
+	if !ok {
+		apiError(w, http.StatusNotFound, "unknown host %q", r.PathValue("host"))
+		return
+	}
+	d.mu.Lock()
+	data := d.hosts[i].encoded
+	d.mu.Unlock()
+	if data == nil {
+		apiError(w, http.StatusServiceUnavailable, "nothing collected from %s yet", r.PathValue("host"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+	w.Write([]byte("\n"))
+}
+
+// parseSince reads how far back to go, as a duration like 5m or a time
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if len(s) == 0 {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad since %q, want a duration like 5m or an RFC 3339 time", s)
+	}
+	return t, nil
+}
+
+// serveHistory gives the values a metric took on a host, as named in alert
+// rules, over the rounds kept
+func (d *Dashboard) serveHistory(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("host")
+	i, ok := d.lookup(name)
+	if !ok {
+		apiError(w, http.StatusNotFound, "unknown host %q", name)
+		return
+	}
+	query := r.URL.Query()
+	if len(query.Get("metric")) == 0 {
+		apiError(w, http.StatusBadRequest, "missing metric, such as cpu.user or fs[\"/\"].used_pct")
+		return
+	}
+	metric, err := alert.ParseMetric(query.Get("metric"))
+	if err != nil {
+		apiError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	since, err := parseSince(query.Get("since"), time.Now())
+	if err != nil {
+		apiError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	d.mu.Lock()
+	snaps := d.hosts[i].history
+	d.mu.Unlock()
+
+	h := metricHistory{Host: name, Metric: query.Get("metric"), Points: []point{}}
+	for _, snap := range snaps {
+		if snap.Timestamp.Before(since) {
+			continue
+		}
+		if v, ok := metric.Value(snap); ok {
+			h.Points = append(h.Points, point{Time: snap.Timestamp, Value: v})
+		}
+	}
+	writeJSON(w, http.StatusOK, h)
+}
+
+// serveStream streams every update as a line of JSON, of every host or of
+// the one given as host
+func (d *Dashboard) serveStream(w http.ResponseWriter, r *http.Request) {
+	host := r.URL.Query().Get("host")
+	if _, ok := d.lookup(host); len(host) > 0 && !ok {
+		apiError(w, http.StatusNotFound, "unknown host %q", host)
+		return
+	}
+	watcher, ok := d.watch()
+	if !ok {
+		apiError(w, http.StatusServiceUnavailable, "shutting down")
+		return
+	}
+	defer d.unwatch(watcher)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	rc := http.NewResponseController(w)
+	for {
+		if err := rc.Flush(); err != nil {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-watcher:
+			if !ok {
+				return
+			}
+			if len(host) > 0 && ev.host != host {
+				continue
+			}
+			w.Write(ev.data)
+			w.Write([]byte("\n"))
+		}
+	}
+}
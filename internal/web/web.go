@@ -0,0 +1,215 @@
+// Package web serves a live dashboard of remote hosts to browsers, with
+// updates pushed as Server-Sent Events from the collection loop, and a JSON
+// API over the snapshots it keeps.
+package web
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/0x0BSoD/rtop/internal/alert"
+	"github.com/0x0BSoD/rtop/internal/output"
+	"github.com/0x0BSoD/rtop/internal/stats"
+	"github.com/0x0BSoD/rtop/pkg/logger"
+)
+
+//go:embed static/index.html
+var index []byte
+
+// backlog is how many updates a browser may lag behind before it misses some
+const backlog = 16
+
+// DefaultHistory is how long snapshots are kept for the API by default
+const DefaultHistory = time.Hour
+
+// update is what browsers are sent for each round of a host
+type update struct {
+	output.Snapshot
+	Alerts []string `json:"alerts,omitempty"` // rules firing on the host
+}
+
+// event is an encoded update of a host
+type event struct {
+	host string
+	data []byte
+}
+
+// hostState is what was collected from a host
+type hostState struct {
+	last    update
+	encoded []byte            // last, for new watchers
+	history []output.Snapshot // of the rounds it was reachable, oldest first
+}
+
+// Dashboard collects from every host each interval, pushes every round to the
+// browsers watching and answers the JSON API from the history it keeps
+type Dashboard struct {
+	UpdateInterval time.Duration
+	History        time.Duration // how long snapshots are kept
+	Alerts         *alert.Engine // nil without rules
+
+	fetchers []*stats.SshFetcher
+	mux      *http.ServeMux
+	mu       sync.Mutex
+	hosts    []hostState
+	watchers map[chan event]struct{}
+	closed   bool
+}
+
+func New(fetchers []*stats.SshFetcher, interval time.Duration) *Dashboard {
+	d := &Dashboard{
+		UpdateInterval: interval,
+		History:        DefaultHistory,
+		fetchers:       fetchers,
+		mux:            http.NewServeMux(),
+		hosts:          make([]hostState, len(fetchers)),
+		watchers:       make(map[chan event]struct{}),
+	}
+	d.mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(index)
+	})
+	d.mux.HandleFunc("GET /events", d.serveEvents)
+	d.mux.HandleFunc("GET /api/v1/hosts", d.serveHosts)
+	d.mux.HandleFunc("GET /api/v1/hosts/{host}/snapshot", d.serveSnapshot)
+	d.mux.HandleFunc("GET /api/v1/hosts/{host}/history", d.serveHistory)
+	d.mux.HandleFunc("GET /api/v1/stream", d.serveStream)
+	return d
+}
+
+// Run collects from every host until ctx is done, and then ends the streams
+// of the browsers still watching
+func (d *Dashboard) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i, f := range d.fetchers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.poll(ctx, i, f)
+		}()
+	}
+	wg.Wait()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	for watcher := range d.watchers {
+		close(watcher)
+		delete(d.watchers, watcher)
+	}
+}
+
+func (d *Dashboard) poll(ctx context.Context, i int, f *stats.SshFetcher) {
+	ticker := time.NewTicker(d.UpdateInterval)
+	defer ticker.Stop()
+	for {
+		f.GetAllStats(ctx)
+		d.publish(i, f)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dashboard) publish(i int, f *stats.SshFetcher) {
+	u := update{Snapshot: output.NewSnapshot(f, time.Now())}
+	if d.Alerts != nil {
+		for _, a := range d.Alerts.Active(f.Name) {
+			u.Alerts = append(u.Alerts, a.Rule.Text)
+		}
+	}
+	data, err := json.Marshal(u)
+	if err != nil {
+		logger.Error("%s: failed to encode update: %v", f.Name, err)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	host := &d.hosts[i]
+	host.last = u
+	host.encoded = data
+	if !u.Reconnecting {
+		host.history = append(host.history, u.Snapshot)
+	}
+	expired := 0
+	for expired < len(host.history) && u.Timestamp.Sub(host.history[expired].Timestamp) > d.History {
+		expired++
+	}
+	host.history = host.history[expired:]
+
+	for watcher := range d.watchers {
+		select {
+		case watcher <- event{host: f.Name, data: data}:
+		default:
+			// Too slow to keep up, the next round will catch it up
+		}
+	}
+}
+
+// watch subscribes to updates, starting with the last one of every host
+func (d *Dashboard) watch() (chan event, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil, false
+	}
+	watcher := make(chan event, backlog+len(d.hosts))
+	for i, host := range d.hosts {
+		if host.encoded != nil {
+			watcher <- event{host: d.fetchers[i].Name, data: host.encoded}
+		}
+	}
+	d.watchers[watcher] = struct{}{}
+	return watcher, true
+}
+
+func (d *Dashboard) unwatch(watcher chan event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.watchers[watcher]; ok {
+		close(watcher)
+		delete(d.watchers, watcher)
+	}
+}
+
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mux.ServeHTTP(w, r)
+}
+
+// serveEvents streams an update event per host and round
+func (d *Dashboard) serveEvents(w http.ResponseWriter, r *http.Request) {
+	watcher, ok := d.watch()
+	if !ok {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer d.unwatch(watcher)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	rc := http.NewResponseController(w)
+	fmt.Fprintf(w, "retry: %d\n\n", d.UpdateInterval.Milliseconds())
+	for {
+		if err := rc.Flush(); err != nil {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-watcher:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "event: update\ndata: %s\n\n", ev.data)
+		}
+	}
+}
@@ -26,46 +26,207 @@ THE SOFTWARE.
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/0x0BSoD/rtop/internal/alert"
+	"github.com/0x0BSoD/rtop/internal/check"
+	"github.com/0x0BSoD/rtop/internal/inventory"
+	"github.com/0x0BSoD/rtop/internal/output"
+	"github.com/0x0BSoD/rtop/internal/prometheus"
+	"github.com/0x0BSoD/rtop/internal/report"
 	"github.com/0x0BSoD/rtop/internal/stats"
 	"github.com/0x0BSoD/rtop/internal/tui"
+	"github.com/0x0BSoD/rtop/internal/web"
 	"github.com/0x0BSoD/rtop/pkg/logger"
-	"github.com/charmbracelet/bubbles/progress"
 	tea "github.com/charmbracelet/bubbletea"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"os/user"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
 const VERSION = "1.0"
 const DEFAULT_REFRESH = 5 // default refresh interval in seconds
+const DEFAULT_LISTEN = ":9111"
+const DEFAULT_WEB_LISTEN = "127.0.0.1:8080"
+const DEFAULT_REPORT_DURATION = 5 * time.Minute
+const DEFAULT_REPORT_FILE = "report.html"
 
 //----------------------------------------------------------------------------
 // Command-line processing
 
+// checkMode makes usage errors exit with UNKNOWN, like monitoring plugins do
+var checkMode bool
+
+// fatal logs an error and exits, in check mode with an UNKNOWN status line
+// since monitoring reads any other exit as the state of the host
+func fatal(format string, v ...interface{}) {
+	if checkMode {
+		fmt.Println(check.Failed(fmt.Errorf(format, v...)))
+		os.Exit(int(check.Unknown))
+	}
+	logger.Fatal(format, v...)
+	os.Exit(1)
+}
+
 func usage(code int) {
+	if code != 0 && checkMode {
+		code = int(check.Unknown)
+		fmt.Println(check.Failed(errors.New("bad command line")))
+	}
 	fmt.Printf(
 		`rtop %s - (c) 2015 RapidLoop - MIT Licensed - http://rtop-monitor.org
 rtop monitors server statistics over an ssh connection
 
-Usage: rtop [-i private-key-file] [-l log-level] [-L log-file] [user@]host[:port] [interval]
+Usage: rtop [compare|serve|web|check|report] [--listen addr] [--duration time] [-o report-file] [-i private-key-file] [-c certificate-file] [-t transport] [-b] [--output format] [-n iterations] [--csv file] [--influx target] [--graphite target] [--otlp endpoint] [--record session-file] [--alerts rules-file] [-f host-file] [--inventory file] [--ssh-config] [-g group] [--sudo] [--sudo-password] [--passphrase-command cmd] [--timeout secs] [--keepalive secs] [-l log-level] [-L log-file] [[user@]host[:port]|glob]... [interval]
 
 	-i private-key-file
 		Encoded private key file to use (default: ~/.ssh/id_*  if present)
+	-c certificate-file
+		OpenSSH certificate for the key (default: <private-key-file>-cert.pub if present)
+	-t transport
+		native: built-in SSH client (default)
+		ssh: run commands through the system ssh binary, honouring all of ~/.ssh/config
+	-b
+		Batch mode: print plain text snapshots to stdout every interval,
+		starting after the first one, instead of the interactive display
+	--output format
+		Batch mode in this format: text (as -b), or json for one object per
+		snapshot and line, as described by output.Snapshot
+	-n iterations
+		In batch mode, stop after this many snapshots (default: 0, no limit)
+	--csv file
+		Also append a row per host and round to this CSV file, in any mode.
+		Columns are added as new mounts and interfaces appear
+	--influx target
+		Also send every round as InfluxDB line protocol, in any mode. The
+		target is tcp://host:port, udp://host:port or a file
+	--graphite target
+		Also send every round in the Graphite plaintext protocol as
+		rtop.<host>.<metric>, to a target like for --influx
+	--otlp endpoint
+		Also push every round to an OpenTelemetry collector over OTLP/HTTP,
+		such as http://localhost:4318. Headers for it can be given in
+		$OTEL_EXPORTER_OTLP_HEADERS as key=value,key=value
+	--record session-file
+		Also append every round to this file, to be watched again with
+		rtop replay
+	--alerts rules-file
+		Evaluate the alert rules in this file on every round, in any mode,
+		and run its hooks when an alert fires or resolves. See below
+	-f host-file
+		Also monitor the hosts listed in this file, one [user@]host[:port] per
+		line, blank lines and # comments ignored
+	--inventory file
+		Monitor the hosts of an Ansible inventory in INI or YAML format
+	--ssh-config
+		Monitor every host named in ~/.ssh/config, wildcard entries excepted
+	-g group
+		Only the hosts in these inventory groups, separated by commas.
+		Groups prefixed with & narrow the selection and groups prefixed
+		with ! remove hosts, as in Ansible: web,&prod,!staging (default
+		inventory: $ANSIBLE_INVENTORY or /etc/ansible/hosts).
+		Hosts from --ssh-config are in the group ssh_config
+	--sudo
+		Run root-only collectors through passwordless sudo (sudo -n). Of
+		those rtop has, only cgroups is: it reads nothing else that needs
+		root, such as the io or fd of other users' processes or dmesg
+	--sudo-password
+		Like --sudo, but prompt once for the remote sudo password
+	--passphrase-command cmd
+		Shell command printing the passphrase of an encrypted key, which is
+		named in $RTOP_KEY_FILE (default: $RTOP_PASSPHRASE_COMMAND)
+	--timeout secs
+		Give up connecting or running a command after this long (default: 15)
+	--keepalive secs
+		Probe the connection this often, 0 to disable (default: 15)
 	-l log-level
 		Set logging level (DEBUG, INFO, WARN, ERROR, FATAL) (default: FATAL)
 	-L log-file
 		File to write logs to (default: stderr only)
-	[user@]host[:port]
-		the SSH server to connect to, with optional username and port
+	[user@]host[:port]...
+		the SSH servers to connect to, with optional username and port. With
+		more than one, hosts are shown side by side in a grid. Globs such
+		as 'web*' match inventory and ~/.ssh/config host names
 	interval
 		refresh interval in seconds (default: %d)
 
-`, VERSION, DEFAULT_REFRESH)
+SSH passwords are read from $RTOP_PASSWORD_COMMAND (given $RTOP_USER and
+$RTOP_HOST) and key passphrases from the passphrase command when one is set.
+Otherwise they, like the --sudo-password password, are asked for with
+$SSH_ASKPASS as selected by $SSH_ASKPASS_REQUIRE, then on the terminal.
+
+rtop compare hostA hostB shows two hosts next to each other and highlights
+where their CPU breakdown, memory composition, filesystems, interface rates
+and cgroup usage differ significantly.
+
+rtop serve --listen addr host... keeps collecting from the hosts every interval
+and serves their stats on http://addr/metrics for Prometheus, with a host
+label (default address: %s).
+
+rtop web --listen addr host... serves a live dashboard of the hosts on
+http://addr/, updated every interval for every browser watching (default
+address: %s). Alerts from --alerts are shown on it. It also answers a JSON
+API over the snapshots of the last hour:
+	GET /api/v1/hosts
+		The hosts, whether they are reachable and their alerts
+	GET /api/v1/hosts/{host}/snapshot
+		The last snapshot of a host, as in --output json
+	GET /api/v1/hosts/{host}/history?metric=cpu.user&since=5m
+		The values of a metric, named as in alert rules, since a duration
+		ago or an RFC 3339 time (default: all kept)
+	GET /api/v1/stream[?host=host]
+		Every new snapshot as a line of JSON
+
+rtop replay session-file [host] plays a file written with --record in the
+display it was recorded from: space pauses, n and p step, + and - change the
+speed, [ and ] seek by a minute. Files with several hosts play the first one
+unless host is given.
+
+rtop report host --duration time -o report-file [interval] samples the host
+every interval for the duration (default: %s, or until interrupted) and writes
+a static HTML page with its inventory, charts of CPU, memory, disk space and
+network, the busiest processes and cgroups, filesystem fill and the alerts from
+--alerts that fired (default file: %s).
+
+rtop check host [thresholds] [interval] is a Nagios or Icinga plugin. It takes
+the CPU usage over the interval (default: 1), averaged over --samples rounds,
+and prints one status line with performance data. It exits 0 for OK, 1 for
+WARNING, 2 for CRITICAL and 3 for UNKNOWN. Values above a threshold alert:
+	--cpu-warn, --cpu-crit, --mem-warn, --mem-crit, --swap-warn, --swap-crit percent
+	--load-warn, --load-crit load
+		1 minute load average
+	--fs-warn, --fs-crit [mount:]percent
+		Space used on the filesystem at mount, or on every filesystem.
+		May be repeated
+	--samples n
+		Number of rounds to average CPU usage over (default: 1)
+
+Alert rules files hold a rule per line, as metric op value [for duration]
+or metric increased, where op is one of > >= < <= == != and values may end in
+%% or K, M, G, T. An alert fires once its rule has held for the duration:
+	cpu.iowait > 20 for 30s
+	fs["/"].used_pct > 90
+	cgroup["system.slice/nginx.service"].oom_kill increased
+The metrics are cpu.{user,nice,system,idle,iowait,irq,softirq,steal,guest,used},
+load.{1,5,15}, procs.{running,total}, mem.{used_pct,used,free,buffers,cached,total},
+swap.{used_pct,used,free}, fs["mount"].{used_pct,used,free,total},
+net["interface"].{rx_rate,tx_rate,rx,tx} and, for the slices and services
+below /sys/fs/cgroup, cgroup["path"].{memory,memory_pct,cpu,io_read,io_write,oom_kill}.
+Lines starting with exec run a shell command, given $RTOP_ALERT_STATE (firing
+or resolved), $RTOP_ALERT_RULE, $RTOP_ALERT_HOST, $RTOP_ALERT_VALUE and
+$RTOP_ALERT_TIME, and lines starting with webhook POST the same as JSON to a
+URL. In the display, a shows the alerts and their history.
+
+`, VERSION, DEFAULT_REFRESH, DEFAULT_LISTEN, DEFAULT_WEB_LISTEN, DEFAULT_REPORT_DURATION, DEFAULT_REPORT_FILE)
 	os.Exit(code)
 }
 
@@ -78,9 +239,88 @@ func shift(q []string) (ok bool, val string, qnew []string) {
 	return
 }
 
-func parseCmdLine() (host string, port int, user, key string, interval time.Duration, logLevel, logFile string) {
+// target is a host to monitor
+type target struct {
+	name string // as given, [user@]host[:port]
+	host string
+	port int
+	user string
+}
+
+// options holds everything given on the command line
+type options struct {
+	hosts      []string // host arguments, resolved by resolveTargets
+	hostFile   string
+	inventory  string
+	sshConfig  bool
+	group      string
+	key        string
+	cert       string
+	interval   time.Duration
+	logLevel   string
+	logFile    string
+	transport  string
+	sudo       bool
+	sudoPrompt bool
+	passCmd    string
+	timeout    time.Duration
+	keepalive  time.Duration
+	command    string // compare, serve, replay or check, empty to monitor
+	rules      check.Rules
+	samples    int
+	replayFile string
+	record     string
+	alerts     string
+	duration   time.Duration // of a report
+	reportFile string
+	listen     string
+	batch      bool
+	output     string // batch mode format, one of output.Formats
+	iterations int    // batch mode rounds, 0 for no limit
+	csvFile    string
+	influx     string
+	graphite   string
+	otlp       string
+}
+
+// thresholdFlags maps the check flags taking a single threshold to where
+// they go in rules
+func thresholdFlags(rules *check.Rules) map[string]*float64 {
+	return map[string]*float64{
+		"--cpu-warn":  &rules.CPU.Warn,
+		"--cpu-crit":  &rules.CPU.Crit,
+		"--mem-warn":  &rules.Mem.Warn,
+		"--mem-crit":  &rules.Mem.Crit,
+		"--swap-warn": &rules.Swap.Warn,
+		"--swap-crit": &rules.Swap.Crit,
+		"--load-warn": &rules.Load.Warn,
+		"--load-crit": &rules.Load.Crit,
+	}
+}
+
+// seconds parses a non-negative number of seconds given for flag
+func seconds(flag, val string) time.Duration {
+	i, err := strconv.ParseUint(val, 10, 64)
+	if err != nil {
+		fatal("bad %s: %v", flag, err)
+	}
+	return time.Duration(i) * time.Second
+}
+
+func parseCmdLine() (opts options) {
+	opts.keepalive = -1
+	opts.rules = check.NewRules()
+	opts.samples = 1
 	ok, arg, args := shift(os.Args)
-	var argKey, argHost, argInt, argLogLevel, argLogFile string
+	if len(args) > 0 && (args[0] == "compare" || args[0] == "serve" || args[0] == "web" || args[0] == "replay" || args[0] == "check" || args[0] == "report") {
+		opts.command = args[0]
+		checkMode = opts.command == "check"
+		args = args[1:]
+	}
+	var argKey, argCert, argLogLevel, argLogFile, argTransport string
+	var argHosts []string
+	var argGroup bool
+	var argCheck string // the first check flag given
 	for ok {
 		ok, arg, args = shift(args)
 		if !ok {
@@ -89,11 +329,189 @@ func parseCmdLine() (host string, port int, user, key string, interval time.Dura
 		if arg == "-h" || arg == "--help" || arg == "--version" {
 			usage(0)
 		}
-		if arg == "-i" {
+		if arg == "--sudo" {
+			opts.sudo = true
+		} else if arg == "--sudo-password" {
+			opts.sudo = true
+			opts.sudoPrompt = true
+		} else if arg == "--passphrase-command" {
+			ok, opts.passCmd, args = shift(args)
+			if !ok {
+				usage(1)
+			}
+		} else if arg == "--timeout" {
+			var val string
+			ok, val, args = shift(args)
+			if !ok {
+				usage(1)
+			}
+			opts.timeout = seconds(arg, val)
+		} else if arg == "--keepalive" {
+			var val string
+			ok, val, args = shift(args)
+			if !ok {
+				usage(1)
+			}
+			opts.keepalive = seconds(arg, val)
+		} else if arg == "-i" {
 			ok, argKey, args = shift(args)
 			if !ok {
 				usage(1)
 			}
+		} else if arg == "-c" {
+			ok, argCert, args = shift(args)
+			if !ok {
+				usage(1)
+			}
+		} else if arg == "-t" {
+			ok, argTransport, args = shift(args)
+			if !ok || (argTransport != "native" && argTransport != "ssh") {
+				usage(1)
+			}
+		} else if arg == "-f" {
+			ok, opts.hostFile, args = shift(args)
+			if !ok {
+				usage(1)
+			}
+		} else if arg == "--inventory" {
+			ok, opts.inventory, args = shift(args)
+			if !ok {
+				usage(1)
+			}
+		} else if arg == "-b" {
+			opts.batch = true
+		} else if arg == "--output" {
+			ok, opts.output, args = shift(args)
+			if _, known := output.Formats[opts.output]; !ok || !known {
+				usage(1)
+			}
+			opts.batch = true
+		} else if arg == "--csv" {
+			ok, opts.csvFile, args = shift(args)
+			if !ok {
+				usage(1)
+			}
+		} else if arg == "--influx" {
+			ok, opts.influx, args = shift(args)
+			if !ok {
+				usage(1)
+			}
+		} else if arg == "--graphite" {
+			ok, opts.graphite, args = shift(args)
+			if !ok {
+				usage(1)
+			}
+		} else if arg == "--otlp" {
+			ok, opts.otlp, args = shift(args)
+			if !ok {
+				usage(1)
+			}
+		} else if arg == "--record" {
+			ok, opts.record, args = shift(args)
+			if !ok {
+				usage(1)
+			}
+		} else if arg == "--alerts" {
+			ok, opts.alerts, args = shift(args)
+			if !ok {
+				usage(1)
+			}
+		} else if threshold, ok := thresholdFlags(&opts.rules)[arg]; ok {
+			var val string
+			ok, val, args = shift(args)
+			if !ok {
+				usage(1)
+			}
+			v, err := check.ParsePercent(val)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "rtop: %s: %v\n", arg, err)
+				usage(1)
+			}
+			*threshold = v
+			if len(argCheck) == 0 {
+				argCheck = arg
+			}
+		} else if arg == "--fs-warn" || arg == "--fs-crit" {
+			var val string
+			ok, val, args = shift(args)
+			if !ok {
+				usage(1)
+			}
+			mount, v, err := check.ParseFS(val)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "rtop: %s: %v\n", arg, err)
+				usage(1)
+			}
+			t, known := opts.rules.FS[mount]
+			if !known {
+				t = check.NoThreshold()
+			}
+			if arg == "--fs-warn" {
+				t.Warn = v
+			} else {
+				t.Crit = v
+			}
+			opts.rules.FS[mount] = t
+			if len(argCheck) == 0 {
+				argCheck = arg
+			}
+		} else if arg == "--samples" {
+			var val string
+			ok, val, args = shift(args)
+			if !ok {
+				usage(1)
+			}
+			n, err := strconv.ParseUint(val, 10, 16)
+			if err != nil || n == 0 {
+				fmt.Fprintf(os.Stderr, "rtop: bad value for --samples: %s\n", val)
+				usage(1)
+			}
+			opts.samples = int(n)
+			if len(argCheck) == 0 {
+				argCheck = arg
+			}
+		} else if arg == "--duration" {
+			var val string
+			ok, val, args = shift(args)
+			if !ok {
+				usage(1)
+			}
+			d, err := time.ParseDuration(val)
+			if err != nil || d <= 0 {
+				fmt.Fprintf(os.Stderr, "rtop: bad value for --duration: %s\n", val)
+				usage(1)
+			}
+			opts.duration = d
+		} else if arg == "-o" {
+			ok, opts.reportFile, args = shift(args)
+			if !ok {
+				usage(1)
+			}
+		} else if arg == "--listen" {
+			ok, opts.listen, args = shift(args)
+			if !ok {
+				usage(1)
+			}
+		} else if arg == "-n" {
+			var val string
+			ok, val, args = shift(args)
+			if !ok {
+				usage(1)
+			}
+			n, err := strconv.ParseUint(val, 10, 31)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "rtop: bad value for -n: %s\n", val)
+				usage(1)
+			}
+			opts.iterations = int(n)
+		} else if arg == "--ssh-config" {
+			opts.sshConfig = true
+		} else if arg == "-g" || arg == "--group" {
+			ok, opts.group, args = shift(args)
+			if !ok {
+				usage(1)
+			}
+			argGroup = true
 		} else if arg == "-l" {
 			ok, argLogLevel, args = shift(args)
 			if !ok {
@@ -104,78 +522,208 @@ func parseCmdLine() (host string, port int, user, key string, interval time.Dura
 			if !ok {
 				usage(1)
 			}
-		} else if len(argHost) == 0 {
-			argHost = arg
-		} else if len(argInt) == 0 {
-			argInt = arg
+		} else if arg[0] == '-' {
+			usage(1)
 		} else {
+			argHosts = append(argHosts, arg)
+		}
+	}
+
+	// A trailing number is the interval, not a host
+	var argInt string
+	otherSources := len(opts.hostFile) > 0 || len(opts.inventory) > 0 || opts.sshConfig || argGroup
+	if opts.command == "replay" {
+		// replay session-file [host]
+		if len(argHosts) == 0 || len(argHosts) > 2 || otherSources {
 			usage(1)
 		}
+		opts.replayFile, argHosts = argHosts[0], argHosts[1:]
+	} else if n := len(argHosts); n > 0 && (n > 1 || otherSources) {
+		if _, err := strconv.ParseUint(argHosts[n-1], 10, 64); err == nil {
+			argInt = argHosts[n-1]
+			argHosts = argHosts[:n-1]
+		}
+	}
+	if len(argHosts) == 0 && !otherSources && opts.command != "replay" {
+		usage(1)
+	}
+	if len(opts.command) > 0 && opts.batch {
+		usage(1)
 	}
-	if len(argHost) == 0 || argHost[0] == '-' {
+	if len(argCheck) > 0 && opts.command != "check" {
+		fmt.Fprintf(os.Stderr, "rtop: %s only applies to rtop check\n", argCheck)
 		usage(1)
 	}
+	if opts.batch && len(opts.output) == 0 {
+		opts.output = "text"
+	}
 
 	// Set default log level
 	if len(argLogLevel) == 0 {
-		logLevel = "FATAL"
+		opts.logLevel = "FATAL"
 	} else {
-		logLevel = argLogLevel
+		opts.logLevel = argLogLevel
 	}
 
 	// Set log file
-	logFile = argLogFile
+	opts.logFile = argLogFile
+
+	// Set transport
+	if len(argTransport) == 0 {
+		opts.transport = "native"
+	} else {
+		opts.transport = argTransport
+	}
 
 	// key
 	if len(argKey) != 0 {
-		key = argKey
+		opts.key = argKey
 	} // else key remains ""
+	opts.cert = argCert
+
+	opts.hosts = argHosts
+
+	// interval
+	if len(argInt) > 0 {
+		i, err := strconv.ParseUint(argInt, 10, 64)
+		if err != nil {
+			fatal("bad interval: %v", err)
+		}
+		if i <= 0 {
+			fatal("bad interval: %d", i)
+		}
+		opts.interval = time.Duration(i) * time.Second
+	} // else interval remains 0
+
+	return
+}
+
+// parseTarget splits [user@]host[:port]
+func parseTarget(arg string) (t target) {
+	t.name = arg
 
 	// user, addr
 	var addr string
-	if i := strings.Index(argHost, "@"); i != -1 {
-		user = argHost[:i]
-		if i+1 >= len(argHost) {
+	if i := strings.Index(arg, "@"); i != -1 {
+		t.user = arg[:i]
+		if i+1 >= len(arg) {
 			usage(1)
 		}
-		addr = argHost[i+1:]
+		addr = arg[i+1:]
 	} else {
 		// user remains ""
-		addr = argHost
+		addr = arg
 	}
 
 	// addr -> host, port
 	if p := strings.Split(addr, ":"); len(p) == 2 {
-		host = p[0]
+		t.host = p[0]
 		var err error
-		if port, err = strconv.Atoi(p[1]); err != nil {
-			logger.Fatal("bad port: %v", err)
-			usage(1)
+		if t.port, err = strconv.Atoi(p[1]); err != nil {
+			fatal("bad port: %v", err)
 		}
-		if port <= 0 || port >= 65536 {
-			logger.Fatal("bad port: %d", port)
-			usage(1)
+		if t.port <= 0 || t.port >= 65536 {
+			fatal("bad port: %d", t.port)
 		}
 	} else {
-		host = addr
+		t.host = addr
 		// port remains 0
 	}
+	return
+}
 
-	// interval
-	if len(argInt) > 0 {
-		i, err := strconv.ParseUint(argInt, 10, 64)
+// fromInventory turns an inventory entry into a target
+func fromInventory(h inventory.Host) target {
+	t := target{name: h.Name, host: h.Address, port: h.Port, user: h.User}
+	if len(t.host) == 0 {
+		t.host = h.Name
+	}
+	return t
+}
+
+// resolveTargets gathers the hosts from the arguments, the host file and the
+// inventories, expanding globs and dropping duplicates
+func resolveTargets(opts options) ([]target, error) {
+	known := inventory.New()
+
+	invFile := opts.inventory
+	if len(invFile) == 0 && len(opts.group) > 0 {
+		invFile = inventory.DefaultAnsibleInventory()
+	}
+	var ansible *inventory.Inventory
+	if len(invFile) > 0 {
+		inv, err := inventory.LoadAnsible(invFile)
 		if err != nil {
-			logger.Fatal("bad interval: %v", err)
-			usage(1)
+			if len(opts.inventory) > 0 || !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+			logger.Debug("No default inventory at %s", invFile)
+		} else {
+			ansible = inv
+			known.Merge(inv)
 		}
-		if i <= 0 {
-			logger.Fatal("bad interval: %d", i)
-			usage(1)
+	}
+	sshHosts := inventory.FromSshConfig()
+	known.Merge(sshHosts)
+
+	var selected []inventory.Host
+	args := opts.hosts
+	if len(opts.hostFile) > 0 {
+		hosts, err := inventory.LoadList(opts.hostFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read host file: %w", err)
 		}
-		interval = time.Duration(i) * time.Second
-	} // else interval remains 0
+		args = append(args, hosts...)
+	}
+	for _, arg := range args {
+		if inventory.IsPattern(arg) {
+			matched := known.Match(arg)
+			if len(matched) == 0 {
+				return nil, fmt.Errorf("no known host matches %q", arg)
+			}
+			selected = append(selected, matched...)
+		} else if found := known.Match(arg); len(found) == 1 {
+			selected = append(selected, found[0])
+		} else {
+			selected = append(selected, inventory.Host{Name: arg})
+		}
+	}
 
-	return
+	switch {
+	case len(opts.group) > 0:
+		hosts, err := known.Select(opts.group)
+		if err != nil && ansible == nil {
+			return nil, fmt.Errorf("%w, no inventory at %s", err, invFile)
+		}
+		if err != nil {
+			return nil, err
+		}
+		selected = append(selected, hosts...)
+	default:
+		// With hosts named, the inventory is only looked up
+		if len(args) == 0 && ansible != nil {
+			selected = append(selected, ansible.Hosts...)
+		}
+		if opts.sshConfig {
+			selected = append(selected, sshHosts.Hosts...)
+		}
+	}
+
+	var targets []target
+	seen := make(map[string]bool)
+	for _, h := range selected {
+		if seen[h.Name] {
+			continue
+		}
+		seen[h.Name] = true
+		if len(h.Address) == 0 && h.Port == 0 && len(h.User) == 0 {
+			// A plain argument, possibly with user and port
+			targets = append(targets, parseTarget(h.Name))
+			continue
+		}
+		targets = append(targets, fromInventory(h))
+	}
+	return targets, nil
 }
 
 //----------------------------------------------------------------------------
@@ -183,51 +731,473 @@ func parseCmdLine() (host string, port int, user, key string, interval time.Dura
 func main() {
 
 	// get params from command line
-	host, port, username, key, interval, logLevel, logFile := parseCmdLine()
+	opts := parseCmdLine()
+	interval := opts.interval
 
 	// Initialize logging
-	logger.InitLogging(logLevel, true, logFile)
+	logger.InitLogging(opts.logLevel, true, opts.logFile)
 	defer logger.RtopLogger.Close()
 	logger.Info("rtop %s starting up", VERSION)
-	logger.Debug("Command line arguments: host=%s, port=%d, username=%s, key=%s, interval=%v",
-		host, port, username, key, interval)
 
-	// get current user
-	currentUser, err := user.Current()
-	if err != nil {
-		logger.Fatal("Failed to get current user: %v", err)
+	if opts.command == "replay" {
+		replay(opts.replayFile, opts.hosts)
 		return
 	}
-	logger.Debug("Current user: %s", currentUser.Username)
 
-	// fill from ~/.ssh/config if possible
-	sshConfig := filepath.Join(currentUser.HomeDir, ".ssh", "config")
-	if _, err := os.Stat(sshConfig); err == nil {
-		logger.Debug("Found SSH config at %s", sshConfig)
-		if stats.ParseSshConfig(sshConfig) {
-			logger.Debug("Successfully parsed SSH config")
-			shost, sport, suser, skey := stats.GetSshEntry(host)
-			if len(shost) > 0 {
-				logger.Debug("Using host from SSH config: %s", shost)
-				host = shost
-			}
-			if sport != 0 && port == 0 {
-				logger.Debug("Using port from SSH config: %d", sport)
-				port = sport
-			}
-			if len(suser) > 0 && len(username) == 0 {
-				logger.Debug("Using username from SSH config: %s", suser)
-				username = suser
-			}
-			if len(skey) > 0 && len(key) == 0 {
-				logger.Debug("Using key from SSH config: %s", skey)
-				key = skey
+	var engine *alert.Engine
+	if len(opts.alerts) > 0 {
+		config, err := alert.Load(opts.alerts)
+		if err != nil {
+			fatal("%v", err)
+		}
+		engine = alert.NewEngine(config)
+		defer engine.Wait()
+	}
+
+	loadSshConfig()
+	targets, err := resolveTargets(opts)
+	if err != nil {
+		fatal("%v", err)
+	}
+	if len(targets) == 0 {
+		fatal("No hosts to monitor")
+	}
+	if opts.command == "compare" && len(targets) != 2 {
+		fatal("compare needs exactly two hosts, got %d", len(targets))
+	}
+	if opts.command == "report" && len(targets) != 1 {
+		fatal("report takes a single host, got %d", len(targets))
+	}
+	logger.Debug("Command line arguments: hosts=%d, key=%s, cert=%s, interval=%v, transport=%s, sudo=%v",
+		len(targets), opts.key, opts.cert, interval, opts.transport, opts.sudo)
+
+	if len(opts.passCmd) > 0 {
+		stats.PassphraseCommand = opts.passCmd
+	}
+
+	if interval == 0 && opts.command == "check" {
+		interval = time.Second
+	}
+	if interval == 0 {
+		logger.Debug("Using default refresh interval: %d seconds", DEFAULT_REFRESH)
+		interval = DEFAULT_REFRESH * time.Second
+	}
+
+	if opts.timeout > 0 {
+		stats.DialTimeout = opts.timeout
+		stats.CommandTimeout = opts.timeout
+	}
+	if opts.keepalive >= 0 {
+		stats.KeepaliveInterval = opts.keepalive
+	}
+
+	fetchers := make([]*stats.SshFetcher, len(targets))
+	for i, t := range targets {
+		fetchers[i] = stats.NewSshFetcher(nil)
+		fetchers[i].Name = t.name
+		fetchers[i].Redial = dialer(opts, t)
+	}
+	defer func() {
+		for _, f := range fetchers {
+			if f.Transport != nil {
+				f.Transport.Close()
 			}
-		} else {
-			logger.Debug("Failed to parse SSH config")
+		}
+	}()
+
+	if opts.command == "check" {
+		if len(fetchers) != 1 {
+			fmt.Println(check.Failed(fmt.Errorf("check takes a single host, got %d", len(fetchers))))
+			os.Exit(int(check.Unknown))
+		}
+		state := runCheck(fetchers[0], interval, opts)
+		if fetchers[0].Transport != nil {
+			fetchers[0].Transport.Close()
+		}
+		logger.RtopLogger.Close()
+		os.Exit(int(state))
+	}
+
+	if len(fetchers) == 1 && opts.command != "serve" && opts.command != "web" {
+		if err := fetchers[0].Connect(context.Background()); err != nil {
+			logger.Fatal("SSH connect error: %v", err)
+			os.Exit(2)
 		}
 	} else {
+		logger.Info("Connecting to %d hosts", len(fetchers))
+		forEach(fetchers, func(f *stats.SshFetcher) {
+			// Unreachable hosts keep retrying from the grid or exporter
+			if err := f.Connect(context.Background()); err != nil {
+				logger.Error("%s: %v", f.Name, err)
+			}
+		})
+	}
+
+	if opts.sudo {
+		var password string
+		if opts.sudoPrompt {
+			p, err := stats.ReadSecret("[sudo] password for remote user: ")
+			if err != nil {
+				logger.Warn("Failed to read sudo password: %v", err)
+			}
+			password = p
+		}
+		forEach(fetchers, func(f *stats.SshFetcher) {
+			f.EnableSudo(password)
+		})
+		for _, f := range fetchers {
+			for name, reason := range f.Degraded {
+				fmt.Fprintf(os.Stderr, "rtop: %s: %s collector degraded, running without sudo: %s\n", f.Name, name, reason)
+			}
+		}
+	}
+
+	logger.Info("Starting monitoring loop with refresh interval of %v", interval)
+
+	forEach(fetchers, func(f *stats.SshFetcher) {
+		f.GetAllStats(context.Background())
+	})
+
+	// The first round is only a baseline for CPU usage and rates
+	sinks := openSinks(opts)
+	defer func() {
+		for _, sink := range sinks {
+			sink.Close()
+		}
+	}()
+	if len(sinks) > 0 || engine != nil {
+		for _, f := range fetchers {
+			f.OnCollect = func(f *stats.SshFetcher) {
+				// Nothing new from a host that is reconnecting
+				if f.Status().Reconnecting {
+					return
+				}
+				snap := output.NewSnapshot(f, time.Now())
+				if engine != nil {
+					engine.Observe(snap)
+				}
+				for _, sink := range sinks {
+					if err := sink.Write(snap); err != nil {
+						logger.Error("%s: failed to write to sink: %v", f.Name, err)
+					}
+				}
+			}
+		}
+	}
+
+	if opts.batch {
+		runBatch(fetchers, interval, opts.iterations, output.Formats[opts.output])
+		logger.Info("rtop shutting down")
+		return
+	}
+	if opts.command == "serve" {
+		serve(fetchers, interval, opts.listen)
+		logger.Info("rtop shutting down")
+		return
+	}
+	if opts.command == "web" {
+		dashboard(fetchers, interval, opts.listen, engine)
+		logger.Info("rtop shutting down")
+		return
+	}
+	if opts.command == "report" {
+		writeReport(fetchers[0], interval, opts, engine)
+		logger.Info("rtop shutting down")
+		return
+	}
+	var m tea.Model
+	if opts.command == "compare" {
+		m = tui.NewCompare(fetchers[0], fetchers[1], interval)
+	} else if len(fetchers) == 1 {
+		model := tui.NewModel(fetchers[0], interval)
+		model.Alerts = engine
+		m = model
+	} else {
+		m = tui.NewGrid(fetchers, interval).WithAlerts(engine)
+	}
+	p := tea.NewProgram(m, tea.WithAltScreen())
+	if _, err := p.Run(); err != nil {
+		log.Fatal(err)
+	}
+
+	logger.Info("rtop shutting down")
+}
+
+// runBatch prints a snapshot of every host each interval. The stats collected
+// at startup are only a baseline, so CPU usage and rates always cover a full
+// interval.
+func runBatch(fetchers []*stats.SshFetcher, interval time.Duration, iterations int, write output.Writer) {
+	for i := 0; iterations == 0 || i < iterations; i++ {
+		time.Sleep(interval)
+		forEach(fetchers, func(f *stats.SshFetcher) {
+			f.GetAllStats(context.Background())
+		})
+		now := time.Now()
+		for _, f := range fetchers {
+			if err := write(os.Stdout, f, now); err != nil {
+				logger.Fatal("Failed to write snapshot: %v", err)
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+// runCheck collects from f once as a baseline and then for every sample, and
+// prints the plugin output
+func runCheck(f *stats.SshFetcher, interval time.Duration, opts options) check.State {
+	ctx := context.Background()
+	if err := f.Connect(ctx); err != nil {
+		result := check.Failed(err)
+		fmt.Println(result)
+		return result.State
+	}
+	f.GetAllStats(ctx)
+
+	var snaps []output.Snapshot
+	for i := 0; i < opts.samples; i++ {
+		time.Sleep(interval)
+		f.GetAllStats(ctx)
+		if status := f.Status(); status.Reconnecting {
+			result := check.Failed(status.LastError)
+			fmt.Println(result)
+			return result.State
+		}
+		snaps = append(snaps, output.NewSnapshot(f, time.Now()))
+	}
+
+	result := check.Evaluate(opts.rules, snaps)
+	fmt.Println(result)
+	return result.State
+}
+
+// replay plays back the frames of one host from a session file
+func replay(path string, hosts []string) {
+	snaps, err := output.ReadRecording(path)
+	if err != nil && len(snaps) == 0 {
+		logger.Fatal("%v", err)
+		os.Exit(1)
+	}
+	if err != nil {
+		logger.Warn("Replaying what could be read: %v", err)
+	}
+
+	var names []string
+	frames := make(map[string][]tui.Frame)
+	for _, snap := range snaps {
+		if _, ok := frames[snap.Host]; !ok {
+			names = append(names, snap.Host)
+		}
+		frames[snap.Host] = append(frames[snap.Host], tui.Frame{
+			At:       snap.Timestamp,
+			Stats:    snap.Stats(),
+			Degraded: snap.Degraded,
+		})
+	}
+	if len(names) == 0 {
+		logger.Fatal("%s holds no snapshots", path)
+		os.Exit(1)
+	}
+
+	host := names[0]
+	if len(hosts) > 0 {
+		host = hosts[0]
+		if _, ok := frames[host]; !ok {
+			logger.Fatal("%s holds no snapshots of %s, only of %s", path, host, strings.Join(names, ", "))
+			os.Exit(1)
+		}
+	}
+	var note string
+	if len(names) > 1 {
+		note = fmt.Sprintf("%s (of %s)", host, strings.Join(names, ", "))
+	}
+
+	p := tea.NewProgram(tui.NewReplay(host, frames[host], note), tea.WithAltScreen())
+	if _, err := p.Run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// writeReport samples f for the duration, or until interrupted, and writes
+// the report
+func writeReport(f *stats.SshFetcher, interval time.Duration, opts options, engine *alert.Engine) {
+	duration := opts.duration
+	if duration == 0 {
+		duration = DEFAULT_REPORT_DURATION
+	}
+	path := opts.reportFile
+	if len(path) == 0 {
+		path = DEFAULT_REPORT_FILE
+	}
+	// Find out about an unwritable path before sampling, not after
+	file, err := os.Create(path)
+	if err != nil {
+		logger.Fatal("Failed to create report: %v", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Fprintf(os.Stderr, "rtop: sampling %s every %v for %v, interrupt to stop early\n", f.Name, interval, duration)
+	r := report.Sample(ctx, f, duration, interval)
+	if engine != nil {
+		r.AddAlerts(engine)
+	}
+
+	if err := report.Write(file, r); err != nil {
+		logger.Fatal("Failed to write report: %v", err)
+		os.Exit(1)
+	}
+	if err := file.Close(); err != nil {
+		logger.Fatal("Failed to write report: %v", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "rtop: wrote %s\n", path)
+}
+
+// openSinks opens every sink asked for on the command line
+func openSinks(opts options) []output.Sink {
+	var sinks []output.Sink
+	open := func(target string, openFn func(string) (output.Sink, error)) {
+		if len(target) == 0 {
+			return
+		}
+		sink, err := openFn(target)
+		if err != nil {
+			logger.Fatal("%v", err)
+			os.Exit(1)
+		}
+		sinks = append(sinks, sink)
+	}
+	open(opts.csvFile, func(path string) (output.Sink, error) {
+		return output.OpenCSV(path)
+	})
+	open(opts.influx, output.OpenInflux)
+	open(opts.graphite, output.OpenGraphite)
+	open(opts.otlp, output.OpenOTLP)
+	open(opts.record, func(path string) (output.Sink, error) {
+		return output.OpenRecording(path)
+	})
+	return sinks
+}
+
+// serve exports the stats of the hosts to Prometheus until interrupted
+func serve(fetchers []*stats.SshFetcher, interval time.Duration, listen string) {
+	if len(listen) == 0 {
+		listen = DEFAULT_LISTEN
+	}
+	exporter := prometheus.New(fetchers, interval)
+	logger.Info("Serving metrics of %d hosts on %s/metrics", len(fetchers), listen)
+	listenAndServe(listen, exporter, exporter.Run)
+}
+
+// dashboard serves the web dashboard of the hosts until interrupted
+func dashboard(fetchers []*stats.SshFetcher, interval time.Duration, listen string, engine *alert.Engine) {
+	if len(listen) == 0 {
+		listen = DEFAULT_WEB_LISTEN
+	}
+	d := web.New(fetchers, interval)
+	d.Alerts = engine
+	logger.Info("Serving the dashboard of %d hosts on http://%s/", len(fetchers), listen)
+	listenAndServe(listen, d, d.Run)
+}
+
+// listenAndServe serves handler on listen while run collects, until
+// interrupted
+func listenAndServe(listen string, handler http.Handler, run func(ctx context.Context)) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Addr: listen, Handler: handler}
+	go func() {
+		run(ctx)
+	}()
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Fatal("Failed to serve on %s: %v", listen, err)
+		os.Exit(1)
+	}
+}
+
+// forEach runs fn for every fetcher concurrently and waits for all of them
+func forEach(fetchers []*stats.SshFetcher, fn func(f *stats.SshFetcher)) {
+	var wg sync.WaitGroup
+	for _, f := range fetchers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(f)
+		}()
+	}
+	wg.Wait()
+}
+
+// dialer returns the function connecting to a target with the selected transport
+func dialer(opts options, t target) func(ctx context.Context) (stats.Transport, error) {
+	if opts.transport == "ssh" {
+		logger.Info("Connecting to %s through the system ssh client", t.host)
+		return func(ctx context.Context) (stats.Transport, error) {
+			return stats.NewExecTransport(t.user, t.host, t.port, opts.key)
+		}
+	}
+	return resolveNative(t.host, t.port, t.user, opts.key, opts.cert)
+}
+
+// loadSshConfig parses ~/.ssh/config into stats.HostInfo, if there is one
+func loadSshConfig() {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		logger.Warn("Failed to get home directory: %v", err)
+		return
+	}
+	sshConfig := filepath.Join(homeDir, ".ssh", "config")
+	if _, err := os.Stat(sshConfig); err != nil {
 		logger.Debug("SSH config not found at %s", sshConfig)
+		return
+	}
+	logger.Debug("Found SSH config at %s", sshConfig)
+	if stats.ParseSshConfig(sshConfig) {
+		logger.Debug("Successfully parsed SSH config")
+	} else {
+		logger.Debug("Failed to parse SSH config")
+	}
+}
+
+// resolveNative resolves the target against ~/.ssh/config and returns a
+// function connecting to it with the built-in SSH client
+func resolveNative(host string, port int, username, key, cert string) func(ctx context.Context) (stats.Transport, error) {
+	// get current user
+	currentUser, err := user.Current()
+	if err != nil {
+		fatal("Failed to get current user: %v", err)
+	}
+	logger.Debug("Current user: %s", currentUser.Username)
+
+	// fill from ~/.ssh/config if possible
+	shost, sport, suser, skey, scert := stats.GetSshEntry(host)
+	if len(shost) > 0 {
+		logger.Debug("Using host from SSH config: %s", shost)
+		host = shost
+	}
+	if sport != 0 && port == 0 {
+		logger.Debug("Using port from SSH config: %d", sport)
+		port = sport
+	}
+	if len(suser) > 0 && len(username) == 0 {
+		logger.Debug("Using username from SSH config: %s", suser)
+		username = suser
+	}
+	if len(skey) > 0 && len(key) == 0 {
+		logger.Debug("Using key from SSH config: %s", skey)
+		key = skey
+	}
+	if len(scert) > 0 && len(cert) == 0 {
+		logger.Debug("Using certificate from SSH config: %s", scert)
+		cert = scert
 	}
 
 	// fill in still-unknown ones with defaults
@@ -248,51 +1218,16 @@ func main() {
 			logger.Debug("Default SSH key not found at %s", idrsap)
 		}
 	}
-	if interval == 0 {
-		logger.Debug("Using default refresh interval: %d seconds", DEFAULT_REFRESH)
-		interval = DEFAULT_REFRESH * time.Second
-	}
 
 	logger.Info("Connecting to %s@%s:%d using key %s", username, host, port, key)
 	addr := fmt.Sprintf("%s:%d", host, port)
-	client, err := stats.SshConnect(username, addr, key)
-	if err != nil {
-		logger.Fatal("SSH connect error: %v", err)
-		os.Exit(2)
-	}
-	logger.Info("Successfully connected to %s", addr)
-
-	sshFetcher := stats.NewSshFetcher(client)
-
-	sshFetcher.ValidateOS()
-
-	logger.Info("Starting monitoring loop with refresh interval of %v", interval)
-
-	// Initialize progress bars
-	progressBars := make(map[string]progress.Model, 10)
-	progressBars["total"] = progress.New(progress.WithScaledGradient("#FF7CCB", "#FDFF8C"))
-	progressBars["system"] = progress.New(progress.WithScaledGradient("#FF7CCB", "#FDFF8C"))
-	progressBars["user"] = progress.New(progress.WithScaledGradient("#FF7CCB", "#FDFF8C"))
-	progressBars["idle"] = progress.New(progress.WithScaledGradient("#FF7CCB", "#FDFF8C"))
-	progressBars["irq"] = progress.New(progress.WithScaledGradient("#FF7CCB", "#FDFF8C"))
-	progressBars["softIrq"] = progress.New(progress.WithScaledGradient("#FF7CCB", "#FDFF8C"))
-	progressBars["iowait"] = progress.New(progress.WithScaledGradient("#FF7CCB", "#FDFF8C"))
-	progressBars["guest"] = progress.New(progress.WithScaledGradient("#FF7CCB", "#FDFF8C"))
-	progressBars["nice"] = progress.New(progress.WithScaledGradient("#FF7CCB", "#FDFF8C"))
-	progressBars["steal"] = progress.New(progress.WithScaledGradient("#FF7CCB", "#FDFF8C"))
-
-	m := tui.Model{
-		SshFetcher:     sshFetcher,
-		UpdateInterval: interval,
-		Bars:           progressBars,
-	}
-	sshFetcher.GetAllStats()
-	tui.InitFsTable(&m)
-	tui.InitNetTable(&m)
-	p := tea.NewProgram(m, tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
-		log.Fatal(err)
+	dialer := stats.NewSshDialer(username, addr, key, cert)
+	return func(ctx context.Context) (stats.Transport, error) {
+		client, err := dialer.Dial(ctx)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Successfully connected to %s", addr)
+		return stats.NewSshTransport(client), nil
 	}
-
-	logger.Info("rtop shutting down")
 }
@@ -0,0 +1,198 @@
+// Package rtop collects the stats of Linux hosts over SSH, or of the local
+// host, the way the rtop command does, for use from other Go programs.
+//
+//	client, err := ssh.Dial("tcp", "db1:22", config)
+//	...
+//	c, err := rtop.NewSSH(client, rtop.WithName("db1"), rtop.WithoutCollectors(rtop.Cgroups))
+//	...
+//	snap, err := c.Collect(ctx)
+//
+// Snapshots follow the schema rtop prints with --output json, and only change
+// along with SchemaVersion.
+package rtop
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/0x0BSoD/rtop/internal/output"
+	"github.com/0x0BSoD/rtop/internal/stats"
+)
+
+// SchemaVersion is the version of Snapshot
+const SchemaVersion = output.SchemaVersion
+
+// Snapshot is one round of stats of a host, made of the others
+type (
+	Snapshot      = output.Snapshot
+	Processes     = output.ProcessesSnapshot
+	CPU           = output.CPUSnapshot
+	Memory        = output.MemorySnapshot
+	Filesystem    = output.FilesystemSnapshot
+	Interface     = output.InterfaceSnapshot
+	Cgroup        = output.CgroupSnapshot
+	CollectorFail = output.CollectorFail
+)
+
+// Transport runs commands on the host, for hosts reached some other way than
+// an *ssh.Client
+type Transport = stats.Transport
+
+// Collectors, to select with WithCollectors and WithoutCollectors
+const (
+	Hostname      = "hostname"
+	Uptime        = "uptime"
+	Load          = "load"
+	MemoryStats   = "memory"
+	Filesystems   = "filesystems"
+	Interfaces    = "interfaces"
+	InterfaceInfo = "interface-info"
+	CPUStats      = "cpu"
+	Cgroups       = "cgroups"
+)
+
+// Collectors lists every collector
+func Collectors() []string {
+	return stats.CollectorNames()
+}
+
+type options struct {
+	name    string
+	only    []string
+	exclude []string
+	sudo    bool
+	sudoPw  string
+}
+
+// Option configures a Collector
+type Option func(*options)
+
+// WithName sets the host in snapshots (default: the remote address, or
+// localhost)
+func WithName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// WithCollectors runs only the collectors named
+func WithCollectors(names ...string) Option {
+	return func(o *options) {
+		o.only = append(o.only, names...)
+	}
+}
+
+// WithoutCollectors skips the collectors named
+func WithoutCollectors(names ...string) Option {
+	return func(o *options) {
+		o.exclude = append(o.exclude, names...)
+	}
+}
+
+// WithSudo runs the collectors that need root through sudo, with password or
+// passwordless when it is empty. Without working sudo they run unprivileged
+// and are listed in Snapshot.Degraded.
+func WithSudo(password string) Option {
+	return func(o *options) {
+		o.sudo = true
+		o.sudoPw = password
+	}
+}
+
+// Collector collects the stats of a host. It is safe for concurrent use, but
+// rounds run one at a time.
+type Collector struct {
+	fetcher *stats.SshFetcher
+	sudo    bool
+	sudoPw  string
+	mu      sync.Mutex
+	probed  bool
+}
+
+// NewSSH collects over client, which stays open and owned by the caller: a
+// round failing on it is reported, and the next one tries it again
+func NewSSH(client *ssh.Client, opts ...Option) (*Collector, error) {
+	return New(borrowed{stats.NewSshTransport(client)}, append([]Option{WithName(client.RemoteAddr().String())}, opts...)...)
+}
+
+// borrowed is a transport over a client of the caller, left open on Close
+type borrowed struct {
+	Transport
+}
+
+func (borrowed) Close() error {
+	return nil
+}
+
+// NewLocal collects the stats of the host it runs on
+func NewLocal(opts ...Option) (*Collector, error) {
+	return New(stats.LocalTransport{}, append([]Option{WithName("localhost")}, opts...)...)
+}
+
+// New collects through transport
+func New(transport Transport, opts ...Option) (*Collector, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	known := make(map[string]bool)
+	for _, name := range stats.CollectorNames() {
+		known[name] = true
+	}
+	for _, name := range append(o.only, o.exclude...) {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown collector %q", name)
+		}
+	}
+	exclude := make(map[string]bool)
+	if len(o.only) > 0 {
+		for name := range known {
+			exclude[name] = true
+		}
+		for _, name := range o.only {
+			delete(exclude, name)
+		}
+	}
+	for _, name := range o.exclude {
+		exclude[name] = true
+	}
+
+	f := stats.NewSshFetcher(transport)
+	f.Name = o.name
+	f.Exclude = exclude
+	return &Collector{fetcher: f, sudo: o.sudo, sudoPw: o.sudoPw}, nil
+}
+
+// Collect runs a round of every selected collector. CPU usage and rates cover
+// the time since the previous round, so they are zero in the first one.
+// Collectors that fail are listed in Snapshot.Errors; an error is returned
+// only when the host cannot be collected from at all.
+func (c *Collector) Collect(ctx context.Context) (Snapshot, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f := c.fetcher
+	if !c.probed {
+		if err := f.Probe(ctx); err != nil {
+			return Snapshot{}, err
+		}
+		if c.sudo {
+			f.EnableSudo(c.sudoPw)
+		}
+		c.probed = true
+	}
+
+	for _, err := range f.GetAllStats(ctx) {
+		var connErr *stats.ConnectionError
+		if errors.As(err, &connErr) {
+			return Snapshot{}, fmt.Errorf("lost connection to %s: %w", f.Name, err)
+		}
+	}
+	return output.NewSnapshot(f, time.Now()), nil
+}
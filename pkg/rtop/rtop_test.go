@@ -0,0 +1,130 @@
+package rtop
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os/exec"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sshServer runs exec requests through /bin/sh, or refuses sessions while
+// reject is set
+func sshServer(t *testing.T, reject *atomic.Bool) string {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for newCh := range chans {
+					if newCh.ChannelType() != "session" || reject.Load() {
+						newCh.Reject(ssh.Prohibited, "no sessions now")
+						continue
+					}
+					ch, reqs, err := newCh.Accept()
+					if err != nil {
+						continue
+					}
+					go serveSession(ch, reqs)
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func serveSession(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	defer ch.Close()
+	for req := range reqs {
+		if req.Type != "exec" || len(req.Payload) < 4 {
+			req.Reply(false, nil)
+			continue
+		}
+		req.Reply(true, nil)
+		cmd := exec.Command("/bin/sh", "-c", string(req.Payload[4:]))
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = ch, ch, ch.Stderr()
+		status := make([]byte, 4)
+		if err := cmd.Run(); err != nil {
+			code := 1
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				code = exitErr.ExitCode()
+			}
+			binary.BigEndian.PutUint32(status, uint32(code))
+		}
+		ch.SendRequest("exit-status", false, status)
+		return
+	}
+}
+
+func TestNewSSHLeavesClientOpen(t *testing.T) {
+	var reject atomic.Bool
+	client, err := ssh.Dial("tcp", sshServer(t, &reject), &ssh.ClientConfig{
+		User:            "test",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	c, err := NewSSH(client, WithName("test"), WithCollectors(Uptime, MemoryStats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := c.Collect(ctx); err != nil {
+		t.Fatalf("first round: %v", err)
+	}
+
+	reject.Store(true)
+	if _, err := c.Collect(ctx); err == nil {
+		t.Fatal("round without sessions did not fail")
+	}
+	reject.Store(false)
+
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatalf("client closed by a failed round: %v", err)
+	}
+	session.Close()
+
+	snap, err := c.Collect(ctx)
+	if err != nil {
+		t.Fatalf("round after the failed one: %v", err)
+	}
+	if snap.Memory.Total == 0 {
+		t.Errorf("no memory collected after the failed round: %+v", snap)
+	}
+}